@@ -2,13 +2,57 @@ package main
 
 import (
 	"compiler/src/app"
+	"compiler/src/formatter"
 	"flag"
+	"fmt"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		formatMain(os.Args[2:])
+		return
+	}
+
 	var inputFile string
+	var options app.Options
 	flag.StringVar(&inputFile, "i", "data/source.src", "Specify input file. Defualt is data/source.src")
+	flag.BoolVar(&options.CaseSensitive, "case-sensitive", false, "Treat identifiers that differ only in case as distinct")
+	flag.StringVar(&options.DumpTokens, "dump-tokens", "", "Write the tokens as JSON lines to this file, - for stdout")
+	flag.StringVar(&options.DumpTree, "dump-tree", "", "Write the parse tree as JSON to this file, - for stdout")
+	flag.StringVar(&options.DumpDOT, "dump-dot", "", "Write the parse tree as a Graphviz digraph to this file, - for stdout")
+	flag.BoolVar(&options.WarnUnusedResult, "warn-unused-result", false, "Warn when a procedure called as a statement returns a value that is thrown away")
 	flag.Parse()
 
-	app.App(inputFile)
+	app.App(inputFile, options)
+}
+
+// formatMain runs the fmt mode: fmt [flags] file...
+func formatMain(args []string) {
+	var options app.FormatOptions
+	var keywordCase string
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fmt [flags] file...")
+		flags.PrintDefaults()
+	}
+	flags.IntVar(&options.IndentWidth, "indent", 4, "Indent each level by this many spaces")
+	flags.StringVar(&keywordCase, "keyword-case", string(formatter.LowerCase), "Print keywords in lower or upper case")
+	flags.BoolVar(&options.Check, "check", false, "List the files that are not formatted and fail if there are any")
+	flags.BoolVar(&options.Write, "w", false, "Write the formatted text back to each file instead of printing it")
+	flags.BoolVar(&options.CaseSensitive, "case-sensitive", false, "Scan the files as the compiler does with --case-sensitive")
+	flags.Parse(args)
+	options.KeywordCase = formatter.KeywordCase(keywordCase)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if options.Check && options.Write {
+		fmt.Fprintln(flags.Output(), "-check and -w cannot be used together")
+		os.Exit(2)
+	}
+
+	if !app.Format(flags.Args(), options) {
+		os.Exit(1)
+	}
 }
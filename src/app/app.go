@@ -1,18 +1,153 @@
 package app
 
 import (
+	"compiler/src/ast"
 	"compiler/src/codegen"
+	"compiler/src/formatter"
 	"compiler/src/parser"
+	"compiler/src/resolver"
 	"compiler/src/scanner"
 	"compiler/src/semanticanalyzer"
+	"fmt"
+	"io"
+	"log"
+	"os"
 )
 
+// Options holds the command line settings for a compile.
+type Options struct {
+	// CaseSensitive selects the strict dialect where identifiers
+	// that differ only in case are distinct.
+	CaseSensitive bool
+	// DumpTokens, DumpTree and DumpDOT name files to write the token
+	// list as JSON lines, the parse tree as JSON and the parse tree
+	// as a Graphviz digraph.  Empty disables a dump, "-" is stdout.
+	DumpTokens string
+	DumpTree   string
+	DumpDOT    string
+	// WarnUnusedResult warns about procedure calls made as
+	// statements, whose result is thrown away.
+	WarnUnusedResult bool
+}
+
+// FormatOptions holds the command line settings for the fmt mode.
+type FormatOptions struct {
+	formatter.Options
+	// Check lists the files that are not formatted instead of
+	// printing them.
+	Check bool
+	// Write replaces each file that is not formatted with its
+	// formatted text.  It cannot be combined with Check.
+	Write bool
+}
+
+// writeDump creates path, or uses stdout for "-", and fills it with write.
+func writeDump(path string, write func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+	if path == "-" {
+		err := write(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	err = write(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Format formats each of files, printing the result unless options
+// asks to check or write the files.  It returns false if a check
+// found a file that is not formatted.
+func Format(files []string, options FormatOptions) bool {
+	formatted := true
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lexer := scanner.NewLexerFromBytes(source, file)
+		lexer.SetOptions(scanner.Options{KeepTrivia: true, CaseSensitive: options.CaseSensitive})
+		tokenList, err := lexer.ScanAll()
+		if err != nil {
+			log.Fatal(err)
+		}
+		text, err := formatter.Format(tokenList, options.Options)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch {
+		case options.Check:
+			if text != string(source) {
+				fmt.Println(file)
+				formatted = false
+			}
+		case options.Write:
+			if text == string(source) {
+				continue
+			}
+			info, err := os.Stat(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = os.WriteFile(file, []byte(text), info.Mode().Perm())
+			if err != nil {
+				log.Fatal(err)
+			}
+		default:
+			fmt.Print(text)
+		}
+	}
+	return formatted
+}
+
 // App ...
-func App(inputFile string) {
-	tokenList := scanner.ScanFile(inputFile)
-	scanner.PrintTokenList(tokenList)
-	parseTreeRoot := parser.Parse(tokenList)
-	parser.PrintParseNodes(&parseTreeRoot, 0)
-	semanticanalyzer.SemanticAnalysis(&parseTreeRoot, parser.GetGlobalSymbolTable(), parser.GetBuiltinSymbolTable())
-	codegen.GenerateC(&parseTreeRoot, parser.GetGlobalSymbolTable(), parser.GetBuiltinSymbolTable())
+func App(inputFile string, options Options) {
+	tokenList, err := scanner.ScanFile(inputFile, scanner.Options{CaseSensitive: options.CaseSensitive})
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeDump(options.DumpTokens, func(writer io.Writer) error {
+		return scanner.WriteTokensJSON(writer, tokenList)
+	})
+	// The tree is dumped even after syntax errors, error nodes and all
+	program, err := parser.Parse(tokenList)
+	parseTreeRoot := program.ParseTree
+	writeDump(options.DumpTree, func(writer io.Writer) error {
+		return parser.WriteParseTreeJSON(writer, &parseTreeRoot)
+	})
+	writeDump(options.DumpDOT, func(writer io.Writer) error {
+		return parser.WriteParseTreeDOT(writer, &parseTreeRoot)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	syntaxTree, err := ast.Build(&parseTreeRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = resolver.Resolve(syntaxTree, resolver.Options{CaseSensitive: options.CaseSensitive})
+	if err != nil {
+		log.Fatal(err)
+	}
+	warnings, err := semanticanalyzer.SemanticAnalysis(syntaxTree, semanticanalyzer.Options{WarnUnusedResult: options.WarnUnusedResult})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		log.Print(warnings)
+	}
+	err = codegen.GenerateC(syntaxTree)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
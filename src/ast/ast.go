@@ -0,0 +1,370 @@
+// Package ast defines the abstract syntax tree that semantic analysis
+// and code generation work on.  Unlike the parse tree it holds no
+// punctuation and no grammar helper productions: each node has named
+// fields for its parts, so later passes do not depend on the position
+// of children in the concrete grammar.
+//
+// Build creates the tree from the parser's parse tree, and the
+// resolver then binds each name in it to its declaration.
+
+package ast
+
+import (
+	"compiler/src/types"
+)
+
+// Node is implemented by every node of the tree.
+type Node interface {
+	Span() types.Span
+}
+
+// Location is embedded in every node to record the source it was
+// built from.
+type Location struct {
+	SourceSpan types.Span
+}
+
+// Span returns the source range covered by the node.
+func (location Location) Span() types.Span {
+	return location.SourceSpan
+}
+
+// Decl is a variable, constant, procedure or type declaration.
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Stmt is a statement.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression.  Its type is STNone until semantic analysis
+// fills it in.
+type Expr interface {
+	Node
+	ExprType() types.STType
+	SetExprType(stType types.STType)
+	exprNode()
+}
+
+// Typed is embedded in every expression to hold its type.
+type Typed struct {
+	Type types.STType
+}
+
+// ExprType returns the type found by semantic analysis.
+func (typed *Typed) ExprType() types.STType {
+	if typed.Type == "" {
+		return types.STNone
+	}
+	return typed.Type
+}
+
+// SetExprType records the type found by semantic analysis.
+func (typed *Typed) SetExprType(stType types.STType) {
+	typed.Type = stType
+}
+
+// Program is the root of the tree.
+type Program struct {
+	Location
+	Name  *Ident
+	Decls []Decl
+	Body  []Stmt
+}
+
+// VarDecl declares a variable or a procedure parameter.  Type is the
+// element type of an array, which has a Bound.  A type given by name
+// is in TypeName, and Type is STNone until the resolver finds it.
+// Length is the value of Bound, found by semantic analysis.  Init is
+// the initial value, an ArrayLit for an array, or nil.
+type VarDecl struct {
+	Location
+	Global   bool
+	Name     *Ident
+	Type     types.STType
+	TypeName *Ident
+	Bound    Expr
+	Length   int
+	Init     Expr
+	// Mode is how a parameter is passed.  It is empty for other
+	// variables.
+	Mode types.ParamMode
+}
+
+// IsArray reports whether the variable is an array.
+func (decl *VarDecl) IsArray() bool {
+	return decl.Bound != nil
+}
+
+// EntryType returns the symbol table type of the variable.
+func (decl *VarDecl) EntryType() types.STType {
+	if decl.IsArray() {
+		return decl.Type.ArrayOf()
+	}
+	return decl.Type
+}
+
+// ProcDecl declares a procedure.  ReturnTypeName is set, like
+// VarDecl.TypeName, when the return type is given by name.
+type ProcDecl struct {
+	Location
+	Global         bool
+	Name           *Ident
+	ReturnType     types.STType
+	ReturnTypeName *Ident
+	Params         []*VarDecl
+	Decls          []Decl
+	Body           []Stmt
+}
+
+// ConstDecl declares Name as a constant with the value of Value,
+// which semantic analysis evaluates.  Type and TypeName are as in
+// VarDecl.
+type ConstDecl struct {
+	Location
+	Global   bool
+	Name     *Ident
+	Type     types.STType
+	TypeName *Ident
+	Value    Expr
+}
+
+// TypeDecl declares Name as the type Def.
+type TypeDecl struct {
+	Location
+	Global bool
+	Name   *Ident
+	Def    TypeDef
+}
+
+// BadDecl stands in for a declaration with a syntax error.
+type BadDecl struct {
+	Location
+}
+
+func (*VarDecl) declNode()   {}
+func (*ConstDecl) declNode() {}
+func (*ProcDecl) declNode()  {}
+func (*TypeDecl) declNode()  {}
+func (*BadDecl) declNode()   {}
+
+// TypeDef is the definition in a type declaration.
+type TypeDef interface {
+	Node
+	typeDefNode()
+}
+
+// EnumDef defines an enum type with the given values.
+type EnumDef struct {
+	Location
+	Values []*Ident
+}
+
+// RecordDef defines a record type with the given fields.
+type RecordDef struct {
+	Location
+	Fields []*VarDecl
+}
+
+func (*EnumDef) typeDefNode()   {}
+func (*RecordDef) typeDefNode() {}
+
+// AssignStmt assigns Value to Target, an *Ident, *IndexExpr or
+// *SelectorExpr.
+type AssignStmt struct {
+	Location
+	Target Expr
+	Value  Expr
+}
+
+// IfStmt is an if statement.  Else is empty when there is no else part.
+type IfStmt struct {
+	Location
+	Cond Expr
+	Then []Stmt
+	Else []Stmt
+}
+
+// ForStmt runs Init once, then Body for as long as Cond holds.
+type ForStmt struct {
+	Location
+	Init *AssignStmt
+	Cond Expr
+	Body []Stmt
+}
+
+// CallStmt calls a procedure and discards its result.
+type CallStmt struct {
+	Location
+	Call *CallExpr
+}
+
+// WhileStmt runs Body for as long as Cond holds.
+type WhileStmt struct {
+	Location
+	Cond Expr
+	Body []Stmt
+}
+
+// CaseStmt runs the body of the clause with a label equal to Subject,
+// or Others if no label matches.  Others is nil without a when
+// others clause.
+type CaseStmt struct {
+	Location
+	Subject Expr
+	Clauses []*CaseClause
+	Others  *CaseClause
+}
+
+// CaseClause is one when clause.  Each label is an IntLit or the
+// Ident of an enum value or constant; the when others clause has none.
+type CaseClause struct {
+	Location
+	Labels []Expr
+	Body   []Stmt
+}
+
+// ExitStmt leaves the innermost loop.
+type ExitStmt struct {
+	Location
+}
+
+// ContinueStmt starts the next iteration of the innermost loop.
+type ContinueStmt struct {
+	Location
+}
+
+// ReturnStmt returns Value from the enclosing procedure.
+type ReturnStmt struct {
+	Location
+	Value Expr
+}
+
+// BadStmt stands in for a statement with a syntax error.
+type BadStmt struct {
+	Location
+}
+
+func (*AssignStmt) stmtNode()   {}
+func (*IfStmt) stmtNode()       {}
+func (*ForStmt) stmtNode()      {}
+func (*CallStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()    {}
+func (*CaseStmt) stmtNode()     {}
+func (*ExitStmt) stmtNode()     {}
+func (*ContinueStmt) stmtNode() {}
+func (*ReturnStmt) stmtNode()   {}
+func (*BadStmt) stmtNode()      {}
+
+// Ident is a use or declaration of a name.  Name is the symbol table
+// key and Spelling is the name as written, for diagnostics.  Entry is
+// the declaration the resolver bound the name to, and is shared by
+// the declaration and every use.  It is nil for the field of a
+// SelectorExpr, which is found from the record's type.
+type Ident struct {
+	Location
+	Typed
+	Name     string
+	Spelling string
+	Entry    *types.STEntry
+}
+
+// BinaryExpr applies the operator Op to X and Y.
+type BinaryExpr struct {
+	Location
+	Typed
+	Op types.TokenType
+	X  Expr
+	Y  Expr
+}
+
+// UnaryExpr applies the prefix operator Op, minus or not, to X.
+type UnaryExpr struct {
+	Location
+	Typed
+	Op types.TokenType
+	X  Expr
+}
+
+// CallExpr calls the procedure Fun.
+type CallExpr struct {
+	Location
+	Typed
+	Fun  *Ident
+	Args []Expr
+}
+
+// IndexExpr selects element Index of the array X.
+type IndexExpr struct {
+	Location
+	Typed
+	X     Expr
+	Index Expr
+}
+
+// SelectorExpr selects the field Field of the record X.
+type SelectorExpr struct {
+	Location
+	Typed
+	X     Expr
+	Field *Ident
+}
+
+// IntLit is an integer literal.
+type IntLit struct {
+	Location
+	Typed
+	Value int64
+}
+
+// FloatLit is a float literal.
+type FloatLit struct {
+	Location
+	Typed
+	Value float64
+}
+
+// StringLit is a string literal with its escapes already decoded.
+type StringLit struct {
+	Location
+	Typed
+	Value string
+}
+
+// ArrayLit lists the initial values of an array's elements.  It only
+// appears as the initializer of a variable.
+type ArrayLit struct {
+	Location
+	Typed
+	Elements []Expr
+}
+
+// BoolLit is true or false.
+type BoolLit struct {
+	Location
+	Typed
+	Value bool
+}
+
+// BadExpr stands in for an expression that could not be built.
+type BadExpr struct {
+	Location
+	Typed
+}
+
+func (*Ident) exprNode()        {}
+func (*BinaryExpr) exprNode()   {}
+func (*UnaryExpr) exprNode()    {}
+func (*CallExpr) exprNode()     {}
+func (*IndexExpr) exprNode()    {}
+func (*SelectorExpr) exprNode() {}
+func (*IntLit) exprNode()       {}
+func (*FloatLit) exprNode()     {}
+func (*StringLit) exprNode()    {}
+func (*ArrayLit) exprNode()     {}
+func (*BoolLit) exprNode()      {}
+func (*BadExpr) exprNode()      {}
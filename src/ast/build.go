@@ -0,0 +1,432 @@
+package ast
+
+import (
+	"compiler/src/types"
+)
+
+// Build converts the parse tree rooted at root into an abstract
+// syntax tree.  Error nodes left by syntax error recovery become
+// BadDecl and BadStmt nodes.  A node of a shape the grammar cannot
+// produce is reported as an error.
+func Build(root *types.ParseNode) (*Program, error) {
+	b := builder{}
+	program := b.program(root)
+	return program, b.diagnostics.Err()
+}
+
+type builder struct {
+	diagnostics types.DiagnosticList
+}
+
+func (b *builder) unexpected(node *types.ParseNode) {
+	b.diagnostics.Add(types.NewDiagnostic(node.Span(), "AST Error: unexpected "+string(node.Production)))
+}
+
+// isTerminal reports whether node is the terminal for tokenType.
+func isTerminal(node *types.ParseNode, tokenType types.TokenType) bool {
+	return len(node.ChildNodes) == 0 && node.TerminalToken.TokenType == tokenType
+}
+
+// child returns the first child of node with the production, or nil.
+func child(node *types.ParseNode, production types.ProductionType) *types.ParseNode {
+	if node == nil {
+		return nil
+	}
+	for i := range node.ChildNodes {
+		if node.ChildNodes[i].Production == production {
+			return &node.ChildNodes[i]
+		}
+	}
+	return nil
+}
+
+// typeMark returns the type keyword among the children of node, or
+// the name of a declared type used in its place.
+func (b *builder) typeMark(node *types.ParseNode) (types.STType, *Ident) {
+	for i := range node.ChildNodes {
+		child := &node.ChildNodes[i]
+		if child.Production == types.TypeMarkProd {
+			return types.STNone, b.ident(child)
+		}
+		switch child.TerminalToken.TokenType {
+		case types.IntegerKeyword, types.FloatKeyword, types.StringKeyword, types.BoolKeyword:
+			return types.STType(child.TerminalToken.TokenType), nil
+		}
+	}
+	return types.STNone, nil
+}
+
+func (b *builder) ident(node *types.ParseNode) *Ident {
+	if node == nil {
+		return &Ident{}
+	}
+	token := node.TerminalToken
+	return &Ident{Location: Location{token.Span}, Name: token.StringValue, Spelling: token.Lexeme}
+}
+
+func (b *builder) program(node *types.ParseNode) *Program {
+	program := &Program{Location: Location{node.Span()}}
+	program.Name = b.ident(child(child(node, types.ProgramHeaderProd), types.IdentifierProd))
+	body := child(node, types.ProgramBodyProd)
+	if body != nil {
+		program.Decls, program.Body = b.block(body)
+	}
+	return program
+}
+
+// block splits a program or procedure body into its declarations,
+// before the begin keyword, and its statements.
+func (b *builder) block(node *types.ParseNode) ([]Decl, []Stmt) {
+	var decls []Decl
+	var stmts []Stmt
+	inStatements := false
+	for i := range node.ChildNodes {
+		child := &node.ChildNodes[i]
+		switch {
+		case isTerminal(child, types.BeginKeyword):
+			inStatements = true
+		case child.Production == types.DeclarationProd:
+			decls = append(decls, b.declaration(child))
+		case child.Production == types.StatementProd:
+			stmts = append(stmts, b.statement(child))
+		case child.Production == types.ErrorProd && inStatements:
+			stmts = append(stmts, &BadStmt{Location{child.Span()}})
+		case child.Production == types.ErrorProd:
+			decls = append(decls, &BadDecl{Location{child.Span()}})
+		}
+	}
+	return decls, stmts
+}
+
+// statements returns the statements among the children of node.
+func (b *builder) statements(children []types.ParseNode) []Stmt {
+	var stmts []Stmt
+	for i := range children {
+		child := &children[i]
+		switch child.Production {
+		case types.StatementProd:
+			stmts = append(stmts, b.statement(child))
+		case types.ErrorProd:
+			stmts = append(stmts, &BadStmt{Location{child.Span()}})
+		}
+	}
+	return stmts
+}
+
+func (b *builder) declaration(node *types.ParseNode) Decl {
+	global := len(node.ChildNodes) > 0 && isTerminal(&node.ChildNodes[0], types.GlobalKeyword)
+	if decl := child(node, types.ProcedureDeclarationProd); decl != nil {
+		return b.procDecl(decl, global)
+	}
+	if decl := child(node, types.VariableDeclarationProd); decl != nil {
+		return b.varDecl(decl, global)
+	}
+	if decl := child(node, types.TypeDeclarationProd); decl != nil {
+		return b.typeDecl(decl, global)
+	}
+	if decl := child(node, types.ConstantDeclarationProd); decl != nil {
+		return b.constDecl(decl, global)
+	}
+	b.unexpected(node)
+	return &BadDecl{Location{node.Span()}}
+}
+
+func (b *builder) varDecl(node *types.ParseNode, global bool) *VarDecl {
+	decl := &VarDecl{
+		Location: Location{node.Span()},
+		Global:   global,
+		Name:     b.ident(child(node, types.IdentifierProd)),
+	}
+	decl.Type, decl.TypeName = b.typeMark(node)
+	if bound := child(node, types.BoundProd); bound != nil {
+		decl.Bound = b.expr(child(bound, types.ExpressionProd))
+	}
+	if init := child(node, types.InitializerProd); init != nil {
+		decl.Init = b.initializer(init)
+	}
+	return decl
+}
+
+// initializer builds the initial value of a variable.
+func (b *builder) initializer(node *types.ParseNode) Expr {
+	list := child(node, types.ArrayLiteralProd)
+	if list == nil {
+		return b.expr(child(node, types.ExpressionProd))
+	}
+	lit := &ArrayLit{Location: Location{list.Span()}}
+	for i := range list.ChildNodes {
+		if list.ChildNodes[i].Production == types.ExpressionProd {
+			lit.Elements = append(lit.Elements, b.expr(&list.ChildNodes[i]))
+		}
+	}
+	return lit
+}
+
+func (b *builder) constDecl(node *types.ParseNode, global bool) *ConstDecl {
+	decl := &ConstDecl{
+		Location: Location{node.Span()},
+		Global:   global,
+		Name:     b.ident(child(node, types.IdentifierProd)),
+		Value:    b.expr(child(node, types.ExpressionProd)),
+	}
+	decl.Type, decl.TypeName = b.typeMark(node)
+	return decl
+}
+
+func (b *builder) procDecl(node *types.ParseNode, global bool) *ProcDecl {
+	header := child(node, types.ProcedureHeaderProd)
+	if header == nil {
+		b.unexpected(node)
+		return &ProcDecl{Location: Location{node.Span()}, Name: &Ident{}}
+	}
+	decl := &ProcDecl{
+		Location: Location{node.Span()},
+		Global:   global,
+		Name:     b.ident(child(header, types.IdentifierProd)),
+	}
+	decl.ReturnType, decl.ReturnTypeName = b.typeMark(header)
+	if params := child(header, types.ParamaterListProd); params != nil {
+		for i := range params.ChildNodes {
+			if param := child(&params.ChildNodes[i], types.VariableDeclarationProd); param != nil {
+				paramDecl := b.varDecl(param, false)
+				paramDecl.Mode = types.InMode
+				if mode := child(&params.ChildNodes[i], types.KeywordTerminal); mode != nil {
+					paramDecl.Mode = types.ParamMode(mode.TerminalToken.StringValue)
+				}
+				decl.Params = append(decl.Params, paramDecl)
+			}
+		}
+	}
+	if body := child(node, types.ProcedureBodyProd); body != nil {
+		decl.Decls, decl.Body = b.block(body)
+	}
+	return decl
+}
+
+func (b *builder) typeDecl(node *types.ParseNode, global bool) *TypeDecl {
+	decl := &TypeDecl{
+		Location: Location{node.Span()},
+		Global:   global,
+		Name:     b.ident(child(node, types.IdentifierProd)),
+	}
+	if enum := child(node, types.EnumTypeProd); enum != nil {
+		def := &EnumDef{Location: Location{enum.Span()}}
+		for i := range enum.ChildNodes {
+			if enum.ChildNodes[i].Production == types.IdentifierProd {
+				def.Values = append(def.Values, b.ident(&enum.ChildNodes[i]))
+			}
+		}
+		decl.Def = def
+		return decl
+	}
+	if record := child(node, types.RecordTypeProd); record != nil {
+		def := &RecordDef{Location: Location{record.Span()}}
+		for i := range record.ChildNodes {
+			if record.ChildNodes[i].Production == types.VariableDeclarationProd {
+				def.Fields = append(def.Fields, b.varDecl(&record.ChildNodes[i], false))
+			}
+		}
+		decl.Def = def
+		return decl
+	}
+	b.unexpected(node)
+	return decl
+}
+
+func (b *builder) statement(node *types.ParseNode) Stmt {
+	if len(node.ChildNodes) == 0 {
+		b.unexpected(node)
+		return &BadStmt{Location{node.Span()}}
+	}
+	stmt := &node.ChildNodes[0]
+	switch stmt.Production {
+	case types.AssignmentStatementProd:
+		return b.assignStmt(stmt)
+	case types.IfStatementProd:
+		return b.ifStmt(stmt)
+	case types.LoopStatementProd:
+		return b.forStmt(stmt)
+	case types.ProcedureCallProd:
+		return &CallStmt{Location: Location{stmt.Span()}, Call: b.call(stmt)}
+	case types.WhileStatementProd:
+		return &WhileStmt{Location: Location{stmt.Span()}, Cond: b.expr(child(stmt, types.ExpressionProd)), Body: b.statements(stmt.ChildNodes)}
+	case types.CaseStatementProd:
+		return b.caseStmt(stmt)
+	case types.ExitStatementProd:
+		return &ExitStmt{Location{stmt.Span()}}
+	case types.ContinueStatementProd:
+		return &ContinueStmt{Location{stmt.Span()}}
+	case types.ReturnStatementProd:
+		return &ReturnStmt{Location: Location{stmt.Span()}, Value: b.expr(child(stmt, types.ExpressionProd))}
+	}
+	b.unexpected(stmt)
+	return &BadStmt{Location{stmt.Span()}}
+}
+
+func (b *builder) caseStmt(node *types.ParseNode) *CaseStmt {
+	stmt := &CaseStmt{Location: Location{node.Span()}, Subject: b.expr(child(node, types.ExpressionProd))}
+	for i := range node.ChildNodes {
+		alternative := &node.ChildNodes[i]
+		if alternative.Production != types.CaseAlternativeProd {
+			continue
+		}
+		clause := &CaseClause{Location: Location{alternative.Span()}, Body: b.statements(alternative.ChildNodes)}
+		for j := range alternative.ChildNodes {
+			if alternative.ChildNodes[j].Production == types.CaseLabelProd {
+				clause.Labels = append(clause.Labels, b.caseLabel(&alternative.ChildNodes[j]))
+			}
+		}
+		if child(alternative, types.CaseLabelProd) == nil {
+			stmt.Others = clause
+		} else {
+			stmt.Clauses = append(stmt.Clauses, clause)
+		}
+	}
+	return stmt
+}
+
+// caseLabel builds a case label, folding a minus sign into the literal.
+func (b *builder) caseLabel(node *types.ParseNode) Expr {
+	if ident := child(node, types.IdentifierProd); ident != nil {
+		return b.ident(ident)
+	}
+	number := child(node, types.NumberProd)
+	if number == nil {
+		b.unexpected(node)
+		return &BadExpr{Location: Location{node.Span()}}
+	}
+	value := number.TerminalToken.IntValue
+	if isTerminal(&node.ChildNodes[0], types.SubtractionOperator) {
+		value = -value
+	}
+	return &IntLit{Location: Location{node.Span()}, Value: value}
+}
+
+func (b *builder) assignStmt(node *types.ParseNode) *AssignStmt {
+	return &AssignStmt{
+		Location: Location{node.Span()},
+		Target:   b.name(child(node, types.DestinationProd)),
+		Value:    b.expr(child(node, types.ExpressionProd)),
+	}
+}
+
+func (b *builder) ifStmt(node *types.ParseNode) *IfStmt {
+	stmt := &IfStmt{Location: Location{node.Span()}, Cond: b.expr(child(node, types.ExpressionProd))}
+	for i := range node.ChildNodes {
+		if isTerminal(&node.ChildNodes[i], types.ElseKeyword) {
+			stmt.Then = b.statements(node.ChildNodes[:i])
+			stmt.Else = b.statements(node.ChildNodes[i+1:])
+			return stmt
+		}
+	}
+	stmt.Then = b.statements(node.ChildNodes)
+	return stmt
+}
+
+func (b *builder) forStmt(node *types.ParseNode) *ForStmt {
+	stmt := &ForStmt{Location: Location{node.Span()}, Cond: b.expr(child(node, types.ExpressionProd))}
+	if init := child(node, types.AssignmentStatementProd); init != nil {
+		stmt.Init = b.assignStmt(init)
+	}
+	stmt.Body = b.statements(node.ChildNodes)
+	return stmt
+}
+
+// expr builds an expression, a binary operation or a factor.  The
+// parser has already nested binary operations by precedence.
+func (b *builder) expr(node *types.ParseNode) Expr {
+	if node == nil {
+		return &BadExpr{}
+	}
+	location := Location{node.Span()}
+	children := node.ChildNodes
+	switch {
+	case node.Production == types.ExpressionProd && len(children) == 1:
+		return b.expr(&children[0])
+	case node.Production == types.BinaryOperationProd && len(children) == 3:
+		return &BinaryExpr{
+			Location: location,
+			Op:       children[1].TerminalToken.TokenType,
+			X:        b.expr(&children[0]),
+			Y:        b.expr(&children[2]),
+		}
+	case node.Production == types.FactorProd && len(children) > 0:
+		return b.factor(node)
+	}
+	b.unexpected(node)
+	return &BadExpr{Location: location}
+}
+
+func (b *builder) factor(node *types.ParseNode) Expr {
+	first := &node.ChildNodes[0]
+	if (isTerminal(first, types.SubtractionOperator) || isTerminal(first, types.NotOperator)) && len(node.ChildNodes) > 1 {
+		return &UnaryExpr{Location: Location{node.Span()}, Op: first.TerminalToken.TokenType, X: b.expr(&node.ChildNodes[1])}
+	}
+	if isTerminal(first, types.OpenRoundBracket) && len(node.ChildNodes) > 1 {
+		return b.expr(&node.ChildNodes[1])
+	}
+	return b.primary(first)
+}
+
+// primary builds a factor that is not parenthesised or negated.
+func (b *builder) primary(node *types.ParseNode) Expr {
+	location := Location{node.Span()}
+	token := node.TerminalToken
+	switch {
+	case node.Production == types.ProcedureCallProd:
+		return b.call(node)
+	case node.Production == types.NameProd:
+		return b.name(node)
+	case node.Production == types.NumberProd && token.TokenType == types.IntegerToken:
+		return &IntLit{Location: location, Value: token.IntValue}
+	case node.Production == types.NumberProd && token.TokenType == types.FloatToken:
+		return &FloatLit{Location: location, Value: token.FloatValue}
+	case node.Production == types.StringProd:
+		return &StringLit{Location: location, Value: token.StringValue}
+	case isTerminal(node, types.TrueKeyword):
+		return &BoolLit{Location: location, Value: true}
+	case isTerminal(node, types.FalseKeyword):
+		return &BoolLit{Location: location, Value: false}
+	}
+	b.unexpected(node)
+	return &BadExpr{Location: location}
+}
+
+func (b *builder) call(node *types.ParseNode) *CallExpr {
+	call := &CallExpr{Location: Location{node.Span()}, Fun: b.ident(child(node, types.IdentifierProd))}
+	if args := child(node, types.ArgumentListProd); args != nil {
+		for i := range args.ChildNodes {
+			if args.ChildNodes[i].Production == types.ExpressionProd {
+				call.Args = append(call.Args, b.expr(&args.ChildNodes[i]))
+			}
+		}
+	}
+	return call
+}
+
+// name builds a Name or Destination: an identifier with an optional
+// index, followed by any number of fields, each with an optional index.
+func (b *builder) name(node *types.ParseNode) Expr {
+	if node == nil {
+		return &BadExpr{}
+	}
+	var x Expr = b.ident(child(node, types.IdentifierProd))
+	for i := range node.ChildNodes {
+		part := &node.ChildNodes[i]
+		switch part.Production {
+		case types.ExpressionProd:
+			span := x.Span().Join(part.Span())
+			if i+1 < len(node.ChildNodes) {
+				span = span.Join(node.ChildNodes[i+1].Span())
+			}
+			x = &IndexExpr{Location: Location{span}, X: x, Index: b.expr(part)}
+		case types.FieldProd:
+			field := b.ident(child(part, types.IdentifierProd))
+			x = &SelectorExpr{Location: Location{x.Span().Join(field.Span())}, X: x, Field: field}
+			if index := child(part, types.ExpressionProd); index != nil {
+				x = &IndexExpr{Location: Location{x.Span().Join(part.Span())}, X: x, Index: b.expr(index)}
+			}
+		}
+	}
+	return x
+}
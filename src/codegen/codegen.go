@@ -1,550 +1,881 @@
 // The entry point function is GenerateC
-// GenerateC setups the head and foot of the C program then
-// the function calls GenNode which recursively visits each node
-// of the parse tree generated by the parser.
-// Functions such as GenExpression or GenVariableDeclaration
-// are called depending on the visited node's production type.
-// These functions generate the appropraite C code.
+// GenerateC creates a Generator which walks the abstract syntax tree
+// and emits C for a simple stack machine.  The machine has registers
+// R and a float memory MM laid out as:
+//
+//	0 ..          global variables
+//	STR_BASE ..   string literals, copied in at startup
+//	HEAP_BASE ..  strings read by getString
+//	STACK_BASE .. the stack, growing upwards
+//
+// R[0] is the stack pointer and R[1] the frame pointer, R[5] the heap
+// pointer and R[6] holds a procedure's return value.  R[2] to R[4]
+// are scratch.  Every procedure becomes a C function.  A caller
+// pushes the arguments and a static link, the frame pointer of the
+// procedure the callee is declared in, through which a nested
+// procedure reaches its enclosing procedures' locals.  The callee
+// saves the frame pointer, makes room for its locals and on return
+// pops the arguments and the link and pushes the result.
 
 package codegen
 
 import (
+	"compiler/src/ast"
 	"compiler/src/types"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
-var program = ""
-var sp = 1024
-var fp = 0
-var sdp = 0
-var tvc = 0
-
-var globalSymbolTable = map[string]types.STEntry{}
-var builtinSymbolTable = map[string]types.STEntry{}
+// Generator holds the state for generating one program.
+type Generator struct {
+	storage    map[*types.STEntry]storage
+	globalSize int
+	stringSize int
+	stringInit strings.Builder
+	prototypes strings.Builder
+	functions  strings.Builder
+	code       *strings.Builder
+	indent     int
+	procCount  int
+	records    map[types.STType]*recordLayout
+	loops      []*loop
+	loopCount  int
+	genError   error
+}
 
-func GenerateC(node *types.ParseNode, parseGlobalSymbolTable map[string]types.STEntry, parseBuiltinSymbolTable map[string]types.STEntry) {
-	globalSymbolTable = parseGlobalSymbolTable
-	builtinSymbolTable = parseBuiltinSymbolTable
+// loop is a loop being generated.  exit jumps to exitLabel after the
+// loop, since a break inside a case statement would only leave the
+// switch.  The label is written only if an exit uses it.
+type loop struct {
+	exitLabel string
+	exited    bool
+}
 
-	GenerateHead()
-	GenNode(node, &globalSymbolTable, types.STEntry{})
-	GenerateFoot()
+// recordLayout is the size of a record type in cells and the place
+// of each field within it.
+type recordLayout struct {
+	size   int
+	fields map[string]fieldLayout
+}
 
-	err := os.WriteFile("c/out.c", []byte(program), 0777)
-	if err != nil {
-		log.Fatal(err)
-	}
+// fieldLayout is a field's offset from the start of its record and
+// its size in cells.
+type fieldLayout struct {
+	offset int
+	size   int
 }
 
-func GenerateHead() {
-	program += "#include <stdio.h>\n"
-	program += "#include <string.h>\n"
-	program += "#include <math.h>\n"
-	program += "\n"
-	program += "int main () {\n"
-	program += "    float R[16];\n"
-	program += "    float MM[1024 * 1024];\n"
+// storage is where a name lives: a procedure's C function, a global
+// variable at an absolute address, or a local variable or parameter
+// at an offset from the frame pointer.  size is the number of cells.
+// An enum value is a constant whose value is kept in address.  A
+// reference parameter takes one cell holding the address of the
+// caller's variable, whose size is size.  depth is the nesting depth
+// of the procedure declaring the name, zero for the program.
+type storage struct {
+	procedure bool
+	cName     string
+	params    []*ast.VarDecl
+	constant  bool
+	global    bool
+	reference bool
+	address   int
+	size      int
+	depth     int
 }
 
-func GenerateFoot() {
-	program += "    return 0;\n"
-	program += "}"
+// scope is the procedure being generated, or nil for the program
+// body, with its nesting depth.  argSize is the number of cells taken
+// by the procedure's arguments.
+type scope struct {
+	procedure *ast.ProcDecl
+	depth     int
+	argSize   int
 }
 
-func GenNode(node *types.ParseNode, localSymbolTable *map[string]types.STEntry, stEntry types.STEntry) {
-	var localST map[string]types.STEntry
-	localST = *localSymbolTable
-	if node.Production == types.ProcedureDeclarationProd {
-		localST = node.ProcLocalSymbolTable
-	}
-	var entry types.STEntry
-	entry = stEntry
-	var identifier types.ParseNode
-	if node.Production == types.ProcedureDeclarationProd {
-		header := node.ChildNodes[0]
-		// if header.ChildNodes[0].TerminalToken.TokenType == types.GlobalKeyword {
-		// 	identifier = node.ChildNodes[2]
-		// } else {
-		// 	identifier = node.ChildNodes[1]
-		// }
-		identifier = header.ChildNodes[1]
-		entry = localST[identifier.TerminalToken.StringValue]
+// NewGenerator returns a Generator.
+func NewGenerator() *Generator {
+	return &Generator{
+		storage: map[*types.STEntry]storage{},
+		records: map[types.STType]*recordLayout{},
 	}
+}
 
-	for _, child := range node.ChildNodes {
-		GenNode(&child, &localST, entry)
+// GenerateC writes the C translation of program to c/out.c.  The
+// program must have passed semantic analysis.
+func GenerateC(program *ast.Program) error {
+	g := NewGenerator()
+	source := g.GenProgram(program)
+	if g.genError != nil {
+		return g.genError
 	}
 
-	// if node.Production == types.DeclarationProd {
-	// 	GenDeclaration(node, &localST)
-	// }
-	if node.Production == types.VariableDeclarationProd {
-		GenVariableDeclaration(node, &localST)
-	}
-	if node.Production == types.ProcedureDeclarationProd {
-		GenProcedureDeclaration(node, &localST)
-	}
-	if node.Production == types.AssignmentStatementProd {
-		// for rule 14
-		GenAssignmentStatement(node, &localST)
-	}
-	if node.Production == types.LoopStatementProd {
-		// for rule 15
-		// check assignment statement
-		GenLoopStatement(node, &localST, entry)
-	}
-	if node.Production == types.IfStatementProd {
-		// for rule 15
-		// check assignment statement
-		GenIfStatement(node, &localST, entry)
+	err := os.WriteFile("c/out.c", []byte(source), 0777)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if node.Production == types.ReturnStatementProd {
-		// for rule 15
-		// check assignment statement
-		GenReturnStatement(node, &localST, entry)
+	return nil
+}
+
+// ReportError records the first code generation error, located at node.
+func (g *Generator) ReportError(node ast.Node, message string) {
+	if g.genError == nil {
+		g.genError = types.NewDiagnostic(node.Span(), "Code Generation Error: "+message)
 	}
 }
 
-func GenDeclaration(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) {
+// emit writes one line of C at the current indentation.
+func (g *Generator) emit(format string, args ...interface{}) {
+	g.code.WriteString(strings.Repeat("    ", g.indent))
+	fmt.Fprintf(g.code, format, args...)
+	g.code.WriteString("\n")
+}
 
+func (g *Generator) push(value string) {
+	g.emit("MM[(int)R[0]] = %s;", value)
+	g.emit("R[0] = R[0] + 1;")
 }
 
-func GenVariableDeclaration(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) {
-	// errString := "Code Generation Error: "
+func (g *Generator) pop(register int) {
+	g.emit("R[0] = R[0] - 1;")
+	g.emit("R[%d] = MM[(int)R[0]];", register)
+}
 
-	identifier := node.ChildNodes[1].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := (*localSymbolTable)[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
+// addressOf returns the C expression for the address of a variable
+// used by a procedure at the given depth.
+func (st storage) addressOf(depth int) string {
+	if st.global {
+		return strconv.Itoa(st.address)
 	}
+	frame := framePointer(depth - st.depth)
+	if st.address < 0 {
+		return frame + " - " + strconv.Itoa(-st.address)
+	}
+	return frame + " + " + strconv.Itoa(st.address)
+}
 
-	stEntry.Pointer = sp
-
-	if existsLocal {
-		(*localSymbolTable)[identifier] = stEntry
-	} else if existsGlobal {
-		globalSymbolTable[identifier] = stEntry
+// framePointer returns the C expression for the frame pointer of the
+// procedure hops levels out from the current one, following the
+// static links.
+func framePointer(hops int) string {
+	frame := "(int)R[1]"
+	for i := 0; i < hops; i++ {
+		frame = "(int)MM[" + frame + " - 2]"
 	}
+	return frame
+}
 
-	sp += 1
+// Lookup returns the storage of the declaration ident is bound to.
+// Builtin procedures have none.
+func (g *Generator) Lookup(ident *ast.Ident) (storage, bool) {
+	st, exists := g.storage[ident.Entry]
+	return st, exists
 }
 
-func GenProcedureDeclaration(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) {
+func (g *Generator) GenProgram(program *ast.Program) string {
+	main := &strings.Builder{}
+	g.code = main
+	g.indent = 1
+	programScope := &scope{}
+	localSize := 0
+	g.GenDeclarations(program.Decls, programScope, &localSize)
+	g.GenInitializers(program.Decls, programScope)
+	g.GenStatements(program.Body, programScope)
+
+	source := "#include <stdio.h>\n"
+	source += "#include <stdlib.h>\n"
+	source += "#include <string.h>\n"
+	source += "#include <math.h>\n"
+	source += "\n"
+	source += "#define STR_BASE " + strconv.Itoa(g.globalSize) + "\n"
+	source += "#define HEAP_BASE (STR_BASE + " + strconv.Itoa(g.stringSize) + ")\n"
+	source += "#define STACK_BASE (1024 * 512)\n"
+	source += "\n"
+	source += "float R[16];\n"
+	source += "float MM[1024 * 1024];\n"
+	source += "\n"
+	source += g.prototypes.String()
+	source += "\n"
+	source += g.functions.String()
+	source += "int main () {\n"
+	source += "    R[0] = STACK_BASE;\n"
+	source += "    R[1] = R[0];\n"
+	source += "    R[5] = HEAP_BASE;\n"
+	source += g.stringInit.String()
+	source += main.String()
+	source += "    return 0;\n"
+	source += "}\n"
+	return source
+}
 
+// GenDeclarations allocates the variables declared in decls and
+// generates the procedures.  Globals get the next absolute addresses,
+// locals the next cells of the frame, counted by localSize.  Every
+// name is given its storage before any procedure is generated, since
+// a procedure may use names declared after it.  The globals declared
+// in a procedure were allocated with the program's.
+func (g *Generator) GenDeclarations(decls []ast.Decl, s *scope, localSize *int) {
+	for _, decl := range decls {
+		if s.procedure == nil {
+			g.Allocate(decl, s.depth, nil)
+		} else if !isGlobal(decl) {
+			g.Allocate(decl, s.depth, localSize)
+		}
+	}
+	if s.procedure == nil {
+		g.AllocateNestedGlobals(decls, 1)
+	}
+	for _, decl := range decls {
+		if decl, ok := decl.(*ast.ProcDecl); ok {
+			g.GenProcedure(decl, g.storage[decl.Name.Entry], s)
+		}
+	}
 }
 
-func GenAssignmentStatement(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) {
-	identifier := node.ChildNodes[0].ChildNodes[0].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := (*localSymbolTable)[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
+// AllocateNestedGlobals allocates the globals declared in the
+// procedures in decls, at the given depth, and in the procedures
+// nested in them.
+func (g *Generator) AllocateNestedGlobals(decls []ast.Decl, depth int) {
+	for _, decl := range decls {
+		proc, ok := decl.(*ast.ProcDecl)
+		if !ok {
+			continue
+		}
+		for _, local := range proc.Decls {
+			if isGlobal(local) {
+				g.Allocate(local, depth, nil)
+			}
+		}
+		g.AllocateNestedGlobals(proc.Decls, depth+1)
 	}
+}
 
-	if stEntry.IsArray {
-		if len(node.ChildNodes[0].ChildNodes) > 1 {
-			GenExpression(&node.ChildNodes[0].ChildNodes[2], localSymbolTable)
-			offset := sp - 1
-			GenExpression(&node.ChildNodes[2], localSymbolTable)
-			offset = (sp - 1) - offset
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "MM[" + strconv.Itoa(stEntry.Pointer) + " + (int)MM[" + strconv.Itoa(offset) + "]] = R[2];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
+// Allocate gives the names declared by decl, in a scope at the given
+// depth, their storage.  A variable is a local counted by localSize,
+// or a global if localSize is nil.
+func (g *Generator) Allocate(decl ast.Decl, depth int, localSize *int) {
+	switch decl := decl.(type) {
+	case *ast.VarDecl:
+		st := storage{size: g.varSize(decl), depth: depth}
+		if localSize == nil {
+			st.global = true
+			st.address = g.globalSize
+			g.globalSize += st.size
 		} else {
-			// full array
+			st.address = *localSize
+			*localSize += st.size
 		}
-
-	} else {
-		GenExpression(&node.ChildNodes[2], localSymbolTable)
-		program += "R[2] = MM[(int)R[0] - 1];\n"
-		program += "MM[" + strconv.Itoa(stEntry.Pointer) + "] = R[2];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+		g.storage[decl.Name.Entry] = st
+	case *ast.ProcDecl:
+		g.procCount += 1
+		g.storage[decl.Name.Entry] = storage{procedure: true, cName: "proc" + strconv.Itoa(g.procCount) + "_" + decl.Name.Name, params: decl.Params, depth: depth}
+	case *ast.TypeDecl:
+		switch def := decl.Def.(type) {
+		case *ast.EnumDef:
+			for ordinal, value := range def.Values {
+				g.storage[value.Entry] = storage{constant: true, address: ordinal}
+			}
+		case *ast.RecordDef:
+			layout := &recordLayout{fields: map[string]fieldLayout{}}
+			for _, field := range def.Fields {
+				size := g.varSize(field)
+				layout.fields[field.Name.Name] = fieldLayout{offset: layout.size, size: size}
+				layout.size += size
+			}
+			g.records[types.RecordType(decl.Name.Name)] = layout
+		default:
+			g.ReportError(decl, "Unknown type definition")
+		}
+	case *ast.ConstDecl:
+		// Uses of a constant are replaced by its value
+	default:
+		g.ReportError(decl, "Unknown declaration")
 	}
 }
 
-func GenLoopStatement(node *types.ParseNode, localSymbolTable *map[string]types.STEntry, stEntry types.STEntry) {
-
+// isGlobal reports whether decl is marked global.
+func isGlobal(decl ast.Decl) bool {
+	switch decl := decl.(type) {
+	case *ast.VarDecl:
+		return decl.Global
+	case *ast.ProcDecl:
+		return decl.Global
+	case *ast.ConstDecl:
+		return decl.Global
+	case *ast.TypeDecl:
+		return decl.Global
+	}
+	return false
 }
 
-func GenIfStatement(node *types.ParseNode, localSymbolTable *map[string]types.STEntry, stEntry types.STEntry) {
-
+// GenInitializers generates code that gives the variables declared in
+// decls their initial values, in the order they are declared.  It runs
+// at program start for the program's declarations and on entry for a
+// procedure's.  A global declared in a procedure keeps its value
+// between calls, so it is initialized once at program start, after
+// the program's own variables.
+func (g *Generator) GenInitializers(decls []ast.Decl, s *scope) {
+	for _, decl := range decls {
+		decl, ok := decl.(*ast.VarDecl)
+		if ok && decl.Init != nil && (s.procedure == nil || !decl.Global) {
+			g.GenInitializer(decl, s)
+		}
+	}
+	if s.procedure == nil {
+		g.GenProcedureGlobalInitializers(decls, s)
+	}
 }
 
-func GenReturnStatement(node *types.ParseNode, localSymbolTable *map[string]types.STEntry, stEntry types.STEntry) {
+// GenProcedureGlobalInitializers initializes the globals declared in
+// the procedures in decls and the procedures nested in them, in the
+// order they are declared.
+func (g *Generator) GenProcedureGlobalInitializers(decls []ast.Decl, s *scope) {
+	for _, decl := range decls {
+		decl, ok := decl.(*ast.ProcDecl)
+		if !ok {
+			continue
+		}
+		for _, local := range decl.Decls {
+			if local, ok := local.(*ast.VarDecl); ok && local.Global && local.Init != nil {
+				g.GenInitializer(local, s)
+			}
+		}
+		g.GenProcedureGlobalInitializers(decl.Decls, s)
+	}
+}
 
+// GenInitializer stores the initial value of decl in its variable.
+func (g *Generator) GenInitializer(decl *ast.VarDecl, s *scope) {
+	list, isList := decl.Init.(*ast.ArrayLit)
+	if !isList {
+		g.GenAssignmentStatement(&ast.AssignStmt{Location: decl.Location, Target: decl.Name, Value: decl.Init}, s)
+		return
+	}
+	st, _ := g.Lookup(decl.Name)
+	elementSize := g.sizeOfType(decl.Type)
+	for i, element := range list.Elements {
+		address := fmt.Sprintf("%s + %d", st.addressOf(s.depth), i*elementSize)
+		g.GenExpression(element, s)
+		if elementSize == 1 {
+			g.GenConversion(element.ExprType(), decl.Type)
+			g.pop(2)
+			g.emit("MM[%s] = R[2];", address)
+			continue
+		}
+		g.emit("R[0] = R[0] - %d;", elementSize)
+		g.emit("memmove(MM + %s, MM + (int)R[0], %d * sizeof(float));", address, elementSize)
+	}
 }
 
-func GenExpression(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
+// sizeOfType returns the number of cells taken by one value of type t.
+func (g *Generator) sizeOfType(t types.STType) int {
+	if layout, exists := g.records[t]; exists {
+		return layout.size
+	}
+	return 1
+}
 
-	stEntry, stType := GenArithOp(&node.ChildNodes[0], localSymbolTable)
-	offset := sp - 1
+// varSize returns the number of cells taken by the variable decl.
+func (g *Generator) varSize(decl *ast.VarDecl) int {
+	if decl.IsArray() {
+		return g.sizeOfType(decl.Type) * decl.Length
+	}
+	return g.sizeOfType(decl.Type)
+}
 
-	if len(node.ChildNodes) > 1 {
-		operation := string(node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType)
-		GenExpressionPrime(&node.ChildNodes[1].ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// argSize returns the number of cells taken by the argument passed
+// for param.
+func (g *Generator) argSize(param *ast.VarDecl) int {
+	if param.Mode.IsReference() {
+		return 1
 	}
-	return stEntry, stType
+	return g.varSize(param)
 }
 
-func GenExpressionPrime(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	operation := string(node.ChildNodes[0].TerminalToken.TokenType)
+// GenProcedure generates decl, declared in parent, as the C function
+// proc.cName.  Arguments sit below the static link and the saved frame
+// pointer, and locals from the frame pointer up.
+func (g *Generator) GenProcedure(decl *ast.ProcDecl, proc storage, parent *scope) {
+	cName := proc.cName
+	s := &scope{procedure: decl, depth: parent.depth + 1}
+	for _, param := range decl.Params {
+		s.argSize += g.argSize(param)
+	}
+	offset := -2 - s.argSize
+	for _, param := range decl.Params {
+		st := storage{address: offset, size: g.varSize(param), reference: param.Mode.IsReference(), depth: s.depth}
+		g.storage[param.Name.Entry] = st
+		offset += g.argSize(param)
+	}
 
-	stEntry, stType := GenArithOp(&node.ChildNodes[1], localSymbolTable)
-	offset := sp - 1
+	savedCode, savedIndent := g.code, g.indent
+	body := &strings.Builder{}
+	g.code = body
+	g.indent = 1
+	localSize := 0
+	g.GenDeclarations(decl.Decls, s, &localSize)
+	g.GenInitializers(decl.Decls, s)
+	g.GenStatements(decl.Body, s)
+	g.emit("R[6] = 0;")
+	g.GenReturn(s)
+	g.code, g.indent = savedCode, savedIndent
+
+	g.prototypes.WriteString("void " + cName + "(void);\n")
+	g.functions.WriteString("void " + cName + "(void) {\n")
+	g.functions.WriteString("    MM[(int)R[0]] = R[1];\n")
+	g.functions.WriteString("    R[0] = R[0] + 1;\n")
+	g.functions.WriteString("    R[1] = R[0];\n")
+	g.functions.WriteString("    R[0] = R[0] + " + strconv.Itoa(localSize) + ";\n")
+	g.functions.WriteString(body.String())
+	g.functions.WriteString("}\n\n")
+}
 
-	if len(node.ChildNodes) > 2 {
-		GenExpressionPrime(&node.ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// GenReturn restores the caller's frame, replaces the arguments and
+// the static link with the value in R[6] and returns.
+func (g *Generator) GenReturn(s *scope) {
+	g.emit("R[2] = MM[(int)R[1] - 1];")
+	g.emit("R[0] = R[1] - %d;", s.argSize+2)
+	g.emit("R[1] = R[2];")
+	g.push("R[6]")
+	g.emit("return;")
+}
+
+func (g *Generator) GenStatements(stmts []ast.Stmt, s *scope) {
+	for _, stmt := range stmts {
+		g.GenStatement(stmt, s)
 	}
-	return stEntry, stType
 }
 
-func GenArithOp(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	stEntry, stType := GenRelation(&node.ChildNodes[0], localSymbolTable)
-	offset := sp - 1
+func (g *Generator) GenStatement(stmt ast.Stmt, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		g.GenAssignmentStatement(stmt, s)
+	case *ast.IfStmt:
+		g.GenExpression(stmt.Cond, s)
+		g.pop(2)
+		g.emit("if (R[2] != 0) {")
+		g.indent += 1
+		g.GenStatements(stmt.Then, s)
+		g.indent -= 1
+		if len(stmt.Else) > 0 {
+			g.emit("} else {")
+			g.indent += 1
+			g.GenStatements(stmt.Else, s)
+			g.indent -= 1
+		}
+		g.emit("}")
+	case *ast.ForStmt:
+		if stmt.Init != nil {
+			g.GenAssignmentStatement(stmt.Init, s)
+		}
+		g.GenLoop(stmt.Cond, stmt.Body, s)
+	case *ast.WhileStmt:
+		g.GenLoop(stmt.Cond, stmt.Body, s)
+	case *ast.CaseStmt:
+		g.GenCaseStatement(stmt, s)
+	case *ast.CallStmt:
+		g.GenExpression(stmt.Call, s)
+		// Drop the unused result
+		g.emit("R[0] = R[0] - 1;")
+	case *ast.ExitStmt:
+		innermost := g.loops[len(g.loops)-1]
+		innermost.exited = true
+		g.emit("goto %s;", innermost.exitLabel)
+	case *ast.ContinueStmt:
+		g.emit("continue;")
+	case *ast.ReturnStmt:
+		g.GenExpression(stmt.Value, s)
+		if s.procedure == nil {
+			g.emit("return 0;")
+			return
+		}
+		g.GenConversion(stmt.Value.ExprType(), s.procedure.ReturnType)
+		g.pop(6)
+		g.GenReturn(s)
+	default:
+		g.ReportError(stmt, "Unknown statement")
+	}
+}
 
-	if len(node.ChildNodes) > 1 {
-		operation := string(node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType)
-		GenArithOpPrime(&node.ChildNodes[1].ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// GenLoop generates a loop running body while cond holds.  The
+// condition is tested at the top, so continue tests it again.
+func (g *Generator) GenLoop(cond ast.Expr, body []ast.Stmt, s *scope) {
+	g.loopCount += 1
+	l := &loop{exitLabel: "loop" + strconv.Itoa(g.loopCount) + "_exit"}
+	g.loops = append(g.loops, l)
+	g.emit("while (1) {")
+	g.indent += 1
+	g.GenExpression(cond, s)
+	g.pop(2)
+	g.emit("if (R[2] == 0) break;")
+	g.GenStatements(body, s)
+	g.indent -= 1
+	g.emit("}")
+	g.loops = g.loops[:len(g.loops)-1]
+	if l.exited {
+		g.emit("%s: ;", l.exitLabel)
 	}
-	return stEntry, stType
 }
 
-func GenArithOpPrime(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	operation := string(node.ChildNodes[0].TerminalToken.TokenType)
+// GenCaseStatement generates a C switch on the subject.  Enum values
+// are switched on by ordinal.
+func (g *Generator) GenCaseStatement(stmt *ast.CaseStmt, s *scope) {
+	g.GenExpression(stmt.Subject, s)
+	g.pop(2)
+	g.emit("switch ((int)R[2]) {")
+	for _, clause := range stmt.Clauses {
+		for _, label := range clause.Labels {
+			g.emit("case %d:", g.caseLabel(label, s))
+		}
+		g.GenCaseBody(clause.Body, s)
+	}
+	if stmt.Others != nil {
+		g.emit("default:")
+		g.GenCaseBody(stmt.Others.Body, s)
+	}
+	g.emit("}")
+}
 
-	stEntry, stType := GenRelation(&node.ChildNodes[1], localSymbolTable)
-	offset := sp - 1
+// GenCaseBody generates the statements of one case clause in their
+// own block, ending with a break.
+func (g *Generator) GenCaseBody(body []ast.Stmt, s *scope) {
+	g.emit("{")
+	g.indent += 1
+	g.GenStatements(body, s)
+	g.emit("break;")
+	g.indent -= 1
+	g.emit("}")
+}
 
-	if len(node.ChildNodes) > 2 {
-		GenArithOpPrime(&node.ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// caseLabel returns the value of a case label.
+func (g *Generator) caseLabel(label ast.Expr, s *scope) int64 {
+	switch label := label.(type) {
+	case *ast.IntLit:
+		return label.Value
+	case *ast.Ident:
+		if label.Entry.IsConstant {
+			return label.Entry.Value.Int
+		}
+		st, _ := g.Lookup(label)
+		return int64(st.address)
 	}
+	g.ReportError(label, "Unknown case label")
+	return 0
+}
 
-	return stEntry, stType
+func (g *Generator) GenAssignmentStatement(stmt *ast.AssignStmt, s *scope) {
+	size := g.SizeOf(stmt.Target, s)
+	if st, ok := g.direct(stmt.Target, s); ok && size == 1 {
+		g.GenExpression(stmt.Value, s)
+		g.GenConversion(stmt.Value.ExprType(), stmt.Target.ExprType())
+		g.pop(2)
+		g.emit("MM[%s] = R[2];", st.addressOf(s.depth))
+		return
+	}
+	g.GenAddress(stmt.Target, s)
+	g.GenExpression(stmt.Value, s)
+	if size == 1 {
+		g.GenConversion(stmt.Value.ExprType(), stmt.Target.ExprType())
+		g.pop(2)
+		g.pop(4)
+		g.emit("MM[(int)R[4]] = R[2];")
+		return
+	}
+	g.emit("R[0] = R[0] - %d;", size)
+	g.pop(4)
+	g.emit("memmove(MM + (int)R[4], MM + (int)R[0] + 1, %d * sizeof(float));", size)
 }
 
-func GenRelation(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	stEntry, stType := GenTerm(&node.ChildNodes[0], localSymbolTable)
-	offset := sp - 1
+// direct returns the storage of expr if it is a variable name whose
+// address is known without following a reference.
+func (g *Generator) direct(expr ast.Expr, s *scope) (storage, bool) {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return storage{}, false
+	}
+	st, _ := g.Lookup(ident)
+	return st, !st.reference
+}
 
-	if len(node.ChildNodes) > 1 {
-		operation := string(node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType)
-		GenRelationPrime(&node.ChildNodes[1].ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// SizeOf returns the number of cells taken by the variable, element
+// or field named by expr.
+func (g *Generator) SizeOf(expr ast.Expr, s *scope) int {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		st, _ := g.Lookup(expr)
+		return st.size
+	case *ast.SelectorExpr:
+		return g.records[expr.X.ExprType()].fields[expr.Field.Name].size
+	default:
+		return g.sizeOfType(expr.ExprType())
 	}
-	return stEntry, stType
 }
 
-func GenRelationPrime(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	operation := string(node.ChildNodes[0].TerminalToken.TokenType)
+// GenAddress generates code that pushes the absolute address of the
+// variable, element or field named by expr.
+func (g *Generator) GenAddress(expr ast.Expr, s *scope) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		st, _ := g.Lookup(expr)
+		if st.reference {
+			g.push("MM[" + st.addressOf(s.depth) + "]")
+		} else {
+			g.push(st.addressOf(s.depth))
+		}
+	case *ast.IndexExpr:
+		elementSize := g.sizeOfType(expr.ExprType())
+		g.GenAddress(expr.X, s)
+		g.GenExpression(expr.Index, s)
+		g.pop(3)
+		g.GenBoundsCheck(expr, g.SizeOf(expr.X, s)/elementSize)
+		g.pop(2)
+		g.push(fmt.Sprintf("R[2] + (int)R[3] * %d", elementSize))
+	case *ast.SelectorExpr:
+		g.GenAddress(expr.X, s)
+		g.pop(2)
+		g.push(fmt.Sprintf("R[2] + %d", g.records[expr.X.ExprType()].fields[expr.Field.Name].offset))
+	default:
+		g.ReportError(expr, "Unknown variable reference")
+	}
+}
 
-	stEntry, stType := GenTerm(&node.ChildNodes[1], localSymbolTable)
-	offset := sp - 1
+// GenLoad generates code that pushes every cell of the variable,
+// element or field named by expr.
+func (g *Generator) GenLoad(expr ast.Expr, s *scope) {
+	size := g.SizeOf(expr, s)
+	if st, ok := g.direct(expr, s); ok {
+		for i := 0; i < size; i++ {
+			g.push("MM[" + st.addressOf(s.depth) + " + " + strconv.Itoa(i) + "]")
+		}
+		return
+	}
+	g.GenAddress(expr, s)
+	g.pop(4)
+	for i := 0; i < size; i++ {
+		g.push("MM[(int)R[4] + " + strconv.Itoa(i) + "]")
+	}
+}
 
-	if len(node.ChildNodes) > 2 {
-		GenRelationPrime(&node.ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
-	}
-
-	return stEntry, stType
+// GenConversion converts the value on top of the stack from one
+// type to another where assignment allows it.  Floats are truncated
+// to integers and integers other than zero become true.
+func (g *Generator) GenConversion(from types.STType, to types.STType) {
+	if from == types.STVarFloat && to == types.STVarInteger {
+		g.emit("MM[(int)R[0] - 1] = (int)MM[(int)R[0] - 1];")
+	}
+	if from == types.STVarInteger && to == types.STVarBool {
+		g.emit("MM[(int)R[0] - 1] = MM[(int)R[0] - 1] != 0;")
+	}
 }
 
-func GenTerm(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	stEntry, stType := GenFactor(&node.ChildNodes[0], localSymbolTable)
-	offset := sp - 1
+// GenBoundsCheck stops the program if the index in R[3] is outside
+// an array of length elements.
+func (g *Generator) GenBoundsCheck(expr *ast.IndexExpr, length int) {
+	position := expr.Span().Start
+	g.emit("if (R[3] < 0 || R[3] >= %d) {", length)
+	g.emit("    fprintf(stderr, \"%d:%d: array index %%d out of bounds\\n\", (int)R[3]);", position.Line, position.Column)
+	g.emit("    exit(1);")
+	g.emit("}")
+}
 
-	if len(node.ChildNodes) > 1 {
-		operation := string(node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType)
-		GenTermPrime(&node.ChildNodes[1].ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
-	}
-
-	return stEntry, stType
+// GenConstant pushes the value of a constant of type stType.
+func (g *Generator) GenConstant(value types.ConstantValue, stType types.STType) {
+	switch stType {
+	case types.STVarFloat:
+		g.push(strconv.FormatFloat(value.Float, 'g', -1, 64))
+	case types.STVarString:
+		g.push(g.AddString(value.String))
+	default:
+		g.push(strconv.FormatInt(value.Int, 10))
+	}
 }
 
-func GenTermPrime(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	operation := string(node.ChildNodes[0].TerminalToken.TokenType)
+// AddString places a string literal in the literal area and returns
+// its address.
+func (g *Generator) AddString(value string) string {
+	address := "STR_BASE + " + strconv.Itoa(g.stringSize)
+	g.stringInit.WriteString("    strcpy((char *)(MM + " + address + "), " + CStringLiteral(value) + ");\n")
+	g.stringSize += (len(value) + 1 + 3) / 4
+	return address
+}
 
-	stEntry, stType := GenFactor(&node.ChildNodes[1], localSymbolTable)
-	offset := sp - 1
-
-	if len(node.ChildNodes) > 2 {
-		GenTermPrime(&node.ChildNodes[2], localSymbolTable)
-		offset = (sp - 1) - offset
-		program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + "];\n"
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-		program += "R[4] = R[2] " + operation + " R[3];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
+// GenExpression generates code that pushes the value of expr.  An
+// array or record name pushes every cell.
+func (g *Generator) GenExpression(expr ast.Expr, s *scope) {
+	switch expr := expr.(type) {
+	case *ast.IntLit:
+		g.push(strconv.FormatInt(expr.Value, 10))
+	case *ast.FloatLit:
+		g.push(strconv.FormatFloat(expr.Value, 'g', -1, 64))
+	case *ast.StringLit:
+		g.push(g.AddString(expr.Value))
+	case *ast.BoolLit:
+		if expr.Value {
+			g.push("1")
+		} else {
+			g.push("0")
+		}
+	case *ast.Ident:
+		st, _ := g.Lookup(expr)
+		if expr.Entry.IsConstant {
+			g.GenConstant(*expr.Entry.Value, expr.ExprType())
+		} else if st.constant {
+			g.push(strconv.Itoa(st.address))
+		} else if st.size == 1 && !st.reference {
+			g.push("MM[" + st.addressOf(s.depth) + "]")
+		} else {
+			g.GenLoad(expr, s)
+		}
+	case *ast.IndexExpr, *ast.SelectorExpr:
+		g.GenLoad(expr, s)
+	case *ast.UnaryExpr:
+		g.GenExpression(expr.X, s)
+		g.pop(2)
+		switch {
+		case expr.Op == types.SubtractionOperator:
+			g.push("-R[2]")
+		case expr.ExprType() == types.STVarBool:
+			g.push("!(int)R[2]")
+		default:
+			g.push("~(int)R[2]")
+		}
+	case *ast.BinaryExpr:
+		g.GenBinaryExpression(expr, s)
+	case *ast.CallExpr:
+		g.GenProcedureCall(expr, s)
+	default:
+		g.ReportError(expr, "Unknown expression")
 	}
-
-	return stEntry, stType
 }
 
-func GenFactor(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	var stType types.STType
-	var stEntry types.STEntry
-	if node.ChildNodes[0].TerminalToken.TokenType == types.SubtractionOperator {
-		if node.ChildNodes[1].Production == types.NameProd {
-			stEntry, stType = GenName(&node.ChildNodes[1], localSymbolTable)
-			if stType != types.STVarBoolArray && stType != types.STVarFloatArray && stType != types.STVarInteger && stType != types.STVarStringArray {
-				program += "R[2] = MM[(int)R[0] - 1];\n"
-				program += "R[2] = R[2] * -1;\n"
-				program += "MM[(int)R[0] - 1] = R[2];\n"
-			} else {
-				offset := stEntry.ArraySize
-				for i := 0; i < stEntry.ArraySize; i++ {
-					program += "R[2] = MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + " + " + strconv.Itoa(i) + "];\n"
-					program += "R[2] = R[2] * -1;\n"
-					program += "MM[(int)R[0] - 1 - " + strconv.Itoa(offset) + " + " + strconv.Itoa(i) + "] = R[2];\n"
-				}
-			}
-		} else if node.ChildNodes[1].Production == types.NumberProd {
-			if node.ChildNodes[1].TerminalToken.TokenType == types.FloatToken {
-				program += "R[2] = " + strconv.FormatFloat(node.ChildNodes[0].TerminalToken.FloatValue, 'f', 10, 64) + ";\n"
-				program += "R[2] = R[2] * -1;\n"
-				program += "MM[(int)R[0]] = R[2];\n"
-				program += "R[0] = R[0] + 1;\n"
-				sp += 1
-				stType = types.STVarFloat
-			} else if node.ChildNodes[1].TerminalToken.TokenType == types.IntegerToken {
-				program += "R[2] = " + strconv.FormatInt(node.ChildNodes[0].TerminalToken.IntValue, 10) + ";\n"
-				program += "R[2] = R[2] * -1;\n"
-				program += "MM[(int)R[0]] = R[2];\n"
-				program += "R[0] = R[0] + 1;\n"
-				sp += 1
-				stType = types.STVarInteger
-			}
+// GenBinaryExpression pops the right operand into R[3] and the left
+// into R[2], and pushes the result.
+func (g *Generator) GenBinaryExpression(expr *ast.BinaryExpr, s *scope) {
+	g.GenExpression(expr.X, s)
+	g.GenExpression(expr.Y, s)
+	g.pop(3)
+	g.pop(2)
+
+	xSTType := expr.X.ExprType()
+	ySTType := expr.Y.ExprType()
+	x, y := "R[2]", "R[3]"
+	operator := string(expr.Op)
+	switch expr.Op {
+	case types.AndOperator, types.OrOperator:
+		x, y = "(int)R[2]", "(int)R[3]"
+	case types.DivisionOperator:
+		if expr.ExprType() == types.STVarInteger {
+			x, y = "(int)R[2]", "(int)R[3]"
 		}
-	} else if node.ChildNodes[0].TerminalToken.TokenType == types.OpenRoundBracket {
-		stEntry, stType = GenExpression(&node.ChildNodes[1], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.ProcedureCallProd {
-		stEntry, stType = GenProcedureCall(&node.ChildNodes[0], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.NameProd {
-		stEntry, stType = GenName(&node.ChildNodes[0], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.NumberProd {
-		if node.ChildNodes[0].TerminalToken.TokenType == types.FloatToken {
-			program += "R[2] = " + strconv.FormatFloat(node.ChildNodes[0].TerminalToken.FloatValue, 'f', 10, 64) + ";\n"
-			program += "MM[(int)R[0]] = R[2];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			stType = types.STVarFloat
-		} else if node.ChildNodes[0].TerminalToken.TokenType == types.IntegerToken {
-			program += "R[2] = " + strconv.FormatInt(node.ChildNodes[0].TerminalToken.IntValue, 10) + ";\n"
-			program += "MM[(int)R[0]] = R[2];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			stType = types.STVarInteger
+	case types.AdditionOperator, types.SubtractionOperator, types.MultiplicationOperator:
+	default:
+		if xSTType == types.STVarString {
+			g.push("strcmp((char *)(MM + (int)R[2]), (char *)(MM + (int)R[3])) " + operator + " 0")
+			return
 		}
-	} else if node.ChildNodes[0].Production == types.StringProd {
-		program += "strcpy((char *)(MM+" + strconv.Itoa(sdp) + "),\"" + node.ChildNodes[0].TerminalToken.StringValue + "\");\n"
-		program += "R[2] = " + strconv.Itoa(sdp) + ";\n"
-		program += "MM[(int)R[0]] = R[2];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sdp += len(node.ChildNodes[0].TerminalToken.StringValue)
-		sp += 1
-		stType = types.STVarString
-	} else if node.ChildNodes[0].TerminalToken.TokenType == types.TrueKeyword {
-		program += "R[2] = 1;\n"
-		program += "MM[(int)R[0]] = R[2];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
-		stType = types.STVarBool
-	} else if node.ChildNodes[0].TerminalToken.TokenType == types.FalseKeyword {
-		program += "R[2] = 0;\n"
-		program += "MM[(int)R[0]] = R[2];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
-		stType = types.STVarBool
-	}
-	return stEntry, stType
-}
-
-func GenProcedureCall(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	lbst := builtinSymbolTable
-	var stType types.STType
-	identifier := node.ChildNodes[0].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := (*localSymbolTable)[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	_, existsBuiltin := lbst[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
-	} else if existsBuiltin {
-		if len(node.ChildNodes) > 3 {
-			GenExpression(&node.ChildNodes[2], localSymbolTable)
+		// A bool compared with an integer sees the integer as a bool
+		if xSTType == types.STVarBool && ySTType == types.STVarInteger {
+			y = "(R[3] != 0)"
 		}
-		if identifier == "putbool" {
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "printf(\"%d\\n\", (int)R[2]);\n"
-		} else if identifier == "putinteger" {
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "printf(\"%d\\n\", (int)R[2]);\n"
-		} else if identifier == "putfloat" {
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "printf(\"%f\\n\", R[2]);\n"
-		} else if identifier == "putstring" {
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "printf(\"%s\\n\", (char *)(MM+(int)R[2]));\n"
-		} else if identifier == "getbool" {
-			temp := "tmp_" + strconv.Itoa(tvc)
-			program += "int " + temp + ";\n"
-			program += "scanf(\"%d\", &" + temp + ");\n"
-			program += "R[3] = " + temp + ";\n"
-			program += "MM[(int)R[0]] = R[3];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			tvc += 1
-		} else if identifier == "getinteger" {
-			temp := "tmp_" + strconv.Itoa(tvc)
-			program += "int " + temp + ";\n"
-			program += "scanf(\"%d\", &" + temp + ");\n"
-			program += "R[3] = " + temp + ";\n"
-			program += "MM[(int)R[0]] = R[3];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			tvc += 1
-		} else if identifier == "getfloat" {
-			temp := "tmp_" + strconv.Itoa(tvc)
-			program += "float " + temp + ";\n"
-			program += "scanf(\"%f\", &" + temp + ");\n"
-			program += "R[3] = " + temp + ";\n"
-			program += "MM[(int)R[0]] = R[3];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			tvc += 1
-		} else if identifier == "getstring" {
-			temp := "tmp_" + strconv.Itoa(tvc)
-			program += "char " + temp + "[80];\n"
-			program += "scanf(\"%s\", " + temp + ");\n"
-			program += "strcpy((char *)(MM+" + strconv.Itoa(sdp) + "), " + temp + ");\n"
-			program += "R[3] = " + strconv.Itoa(sdp) + ";\n"
-			program += "MM[(int)R[0]] = R[3];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			tvc += 1
-		} else if identifier == "sqrt" {
-			temp := "tmp_" + strconv.Itoa(tvc)
-			program += "float " + temp + ";\n"
-			program += "R[2] = MM[(int)R[0] - 1];\n"
-			program += "R[3] = " + "(float) sqrt((int)R[2]);\n"
-			program += "MM[(int)R[0]] = R[3];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
-			tvc += 1
+		if xSTType == types.STVarInteger && ySTType == types.STVarBool {
+			x = "(R[2] != 0)"
 		}
 	}
-
-	return stEntry, stType
+	g.push(x + " " + operator + " " + y)
 }
 
-func GenName(node *types.ParseNode, localSymbolTable *map[string]types.STEntry) (types.STEntry, types.STType) {
-	identifier := node.ChildNodes[0].TerminalToken.StringValue
-
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := (*localSymbolTable)[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
+// GenProcedureCall pushes the arguments and calls the procedure.  An
+// out or inout argument is passed as its address.
+func (g *Generator) GenProcedureCall(call *ast.CallExpr, s *scope) {
+	st, exists := g.Lookup(call.Fun)
+	for i, arg := range call.Args {
+		if exists && st.procedure && st.params[i].Mode.IsReference() {
+			g.GenAddress(arg, s)
+		} else {
+			g.GenExpression(arg, s)
+		}
 	}
-
-	pointer := stEntry.Pointer
-	program += "R[2] = " + strconv.Itoa(pointer) + ";\n"
-
-	if len(node.ChildNodes) > 1 {
-		GenExpression(&node.ChildNodes[2], localSymbolTable)
-		program += "R[3] = MM[(int)R[0] - 1];\n"
-	} else {
-		program += "R[3] = 0;\n"
+	if exists && st.procedure {
+		// A procedure declared in the program needs no static link
+		if st.depth == 0 {
+			g.push("0")
+		} else {
+			g.push(framePointer(s.depth - st.depth))
+		}
+		g.emit("%s();", st.cName)
+		return
 	}
 
-	if len(node.ChildNodes) == 1 && stEntry.IsArray {
-		for i := 0; i < stEntry.ArraySize; i++ {
-			program += "R[4] = MM[(int)R[2] + " + strconv.Itoa(i) + "];\n"
-			program += "MM[(int)R[0]] = R[4];\n"
-			program += "R[0] = R[0] + 1;\n"
-			sp += 1
+	if call.Fun.Entry == nil {
+		g.ReportError(call.Fun, "Unknown procedure "+call.Fun.Spelling)
+		return
+	}
+	builtinName := strings.ToLower(call.Fun.Entry.Name)
+	switch builtinName {
+	case "putbool", "putinteger":
+		g.pop(2)
+		g.emit("printf(\"%%d\\n\", (int)R[2]);")
+		g.push("1")
+	case "putfloat":
+		g.pop(2)
+		g.emit("printf(\"%%f\\n\", R[2]);")
+		g.push("1")
+	case "putstring":
+		g.pop(2)
+		g.emit("printf(\"%%s\\n\", (char *)(MM + (int)R[2]));")
+		g.push("1")
+	case "getbool", "getinteger":
+		g.emit("{")
+		g.emit("    int tmp = 0;")
+		g.emit("    scanf(\"%%d\", &tmp);")
+		g.emit("    R[2] = tmp;")
+		g.emit("}")
+		if builtinName == "getbool" {
+			g.emit("R[2] = R[2] != 0;")
 		}
-	} else {
-		program += "R[4] = MM[(int)R[2] + (int)R[3]];\n"
-		program += "MM[(int)R[0]] = R[4];\n"
-		program += "R[0] = R[0] + 1;\n"
-		sp += 1
-	}
-
-	if len(node.ChildNodes) > 1 {
-		if stEntry.EntryType == types.STVarBoolArray {
-			return stEntry, types.STVarBool
-		} else if stEntry.EntryType == types.STVarFloatArray {
-			return stEntry, types.STVarFloat
-		} else if stEntry.EntryType == types.STVarIntegerArray {
-			return stEntry, types.STVarInteger
-		} else if stEntry.EntryType == types.STVarStringArray {
-			return stEntry, types.STVarString
+		g.push("R[2]")
+	case "getfloat":
+		g.emit("{")
+		g.emit("    float tmp = 0;")
+		g.emit("    scanf(\"%%f\", &tmp);")
+		g.emit("    R[2] = tmp;")
+		g.emit("}")
+		g.push("R[2]")
+	case "getstring":
+		g.emit("*(char *)(MM + (int)R[5]) = 0;")
+		g.emit("scanf(\"%%79s\", (char *)(MM + (int)R[5]));")
+		g.push("R[5]")
+		g.emit("R[5] = R[5] + 20;")
+	case "sqrt":
+		g.pop(2)
+		g.push("(float)sqrt(R[2])")
+	default:
+		g.ReportError(call.Fun, "Unknown builtin procedure "+call.Fun.Spelling)
+	}
+}
+
+// CStringLiteral quotes value as a C string literal.  Anything that
+// is not printable ASCII is written as a three digit octal escape so
+// the literal is safe regardless of what follows it.
+func CStringLiteral(value string) string {
+	literal := "\""
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\' || c == '?':
+			literal += "\\" + string(c)
+		case c == '\n':
+			literal += "\\n"
+		case c == '\t':
+			literal += "\\t"
+		case c < ' ' || c > '~':
+			literal += "\\" + fmt.Sprintf("%03o", c)
+		default:
+			literal += string(c)
 		}
-	} else {
-		return stEntry, stEntry.EntryType
 	}
-	return stEntry, stEntry.EntryType
+	return literal + "\""
 }
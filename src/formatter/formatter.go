@@ -0,0 +1,379 @@
+// The entry point function is Format
+// Format parses a token list with the parser and prints the program
+// in a canonical layout: one declaration or statement per line, the
+// bodies of procedures and compound statements indented, single
+// spaces between tokens except around brackets and before commas and
+// semicolons, and keywords in one case.  Names, literals and comments
+// are kept as written.  Blank lines between declarations and
+// statements are kept, but runs of them become one.
+//
+// Comments come from the trivia the scanner attaches to each token
+// when KeepTrivia is set.  A comment on a line of its own stays on a
+// line of its own, before the token that followed it, and a comment
+// after a token stays at the end of that token's line.
+
+package formatter
+
+import (
+	"compiler/src/parser"
+	"compiler/src/scanner"
+	"compiler/src/types"
+	"errors"
+	"strings"
+)
+
+// KeywordCase is the case keywords are printed in.
+type KeywordCase string
+
+const (
+	// LowerCase prints keywords in lower case
+	LowerCase KeywordCase = "lower"
+	// UpperCase prints keywords in upper case
+	UpperCase KeywordCase = "upper"
+)
+
+// Options selects the layout.
+type Options struct {
+	// IndentWidth is the number of spaces in each level of
+	// indentation.
+	IndentWidth int
+	// KeywordCase is the case keywords are printed in.
+	KeywordCase KeywordCase
+	// CaseSensitive selects the strict dialect.  It must match the
+	// setting the tokens were scanned with.
+	CaseSensitive bool
+}
+
+// printer holds the state for printing one program.
+type printer struct {
+	options Options
+	out     strings.Builder
+	indent  int
+	// lineStart is set when nothing has been written on the current
+	// line, and started once anything has been written at all.
+	lineStart bool
+	started   bool
+	// space asks for a space before the next token, and glue
+	// forbids one, after a unary minus.
+	space bool
+	glue  bool
+	// blank asks for an empty line before the next line.
+	blank bool
+	// breakLine is set after a line comment, which the next token
+	// cannot follow on the same line.
+	breakLine bool
+	// continued is set when a comment has broken a line that the
+	// layout would have kept whole.  The rest of it is indented one
+	// more level.
+	continued bool
+	prev      types.TokenType
+}
+
+// Format returns the program in tokenList, which must have been
+// scanned with KeepTrivia, in the canonical layout.  A program with
+// syntax errors is not formatted.
+func Format(tokenList []types.Token, options Options) (string, error) {
+	if options.IndentWidth < 1 {
+		return "", errors.New("indent width must be at least 1")
+	}
+	if options.KeywordCase != LowerCase && options.KeywordCase != UpperCase {
+		return "", errors.New("keyword case must be " + string(LowerCase) + " or " + string(UpperCase) + ", not " + string(options.KeywordCase))
+	}
+	program, err := parser.Parse(tokenList)
+	if err != nil {
+		return "", err
+	}
+
+	p := &printer{options: options, lineStart: true}
+	p.node(&program.ParseTree)
+	p.newline()
+	// Comments after the end of the program are held by the EOF token
+	if len(tokenList) > 0 && tokenList[len(tokenList)-1].TokenType == types.EOFToken {
+		p.leading(tokenList[len(tokenList)-1].LeadingTrivia)
+		p.newline()
+	}
+	text := p.out.String()
+
+	err = checkTokens(tokenList, text, options)
+	if err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// checkTokens makes sure that text, the formatted program, has the
+// tokens of the original program in tokenList.  Only reserved words
+// may have changed case.
+func checkTokens(tokenList []types.Token, text string, options Options) error {
+	lexer := scanner.NewLexerFromString(text, "")
+	lexer.SetOptions(scanner.Options{CaseSensitive: options.CaseSensitive})
+	formatted, err := lexer.ScanAll()
+	if err != nil {
+		return errors.New("formatting produced a program that does not scan: " + err.Error())
+	}
+	if len(formatted) != len(tokenList) {
+		return errors.New("formatting changed the number of tokens")
+	}
+	for i, token := range tokenList {
+		sameLexeme := token.Lexeme == formatted[i].Lexeme || (isReserved(token) && strings.EqualFold(token.Lexeme, formatted[i].Lexeme))
+		if token.TokenType != formatted[i].TokenType || !sameLexeme {
+			return types.NewDiagnostic(token.Span, "formatting changed the token "+token.Lexeme)
+		}
+	}
+	return nil
+}
+
+// node prints the subtree at node.  Declarations, statements and the
+// parts of compound statements are placed on lines of their own; the
+// rest is printed inline.
+func (p *printer) node(node *types.ParseNode) {
+	switch node.Production {
+	case types.ProgramHeaderProd:
+		p.children(node)
+		p.newline()
+	case types.ProgramBodyProd:
+		p.body(node, false)
+	case types.ProcedureBodyProd:
+		p.body(node, true)
+	case types.RecordTypeProd, types.IfStatementProd, types.LoopStatementProd, types.WhileStatementProd, types.CaseStatementProd, types.CaseAlternativeProd:
+		p.compound(node)
+	default:
+		if len(node.ChildNodes) == 0 {
+			p.token(node)
+			return
+		}
+		p.children(node)
+	}
+}
+
+// children prints the children of node inline.
+func (p *printer) children(node *types.ParseNode) {
+	for i := range node.ChildNodes {
+		child := &node.ChildNodes[i]
+		p.node(child)
+		// A unary minus is written against its operand
+		if i == 0 && (node.Production == types.FactorProd || node.Production == types.CaseLabelProd) && isTerminal(child, types.SubtractionOperator) {
+			p.glue = true
+		}
+	}
+}
+
+// body prints the declarations and statements of the program or a
+// procedure, with begin and end at the level of the header.  The
+// declarations of a procedure are indented.
+func (p *printer) body(node *types.ParseNode, indentDecls bool) {
+	for i := range node.ChildNodes {
+		child := &node.ChildNodes[i]
+		switch {
+		case child.Production == types.DeclarationProd && indentDecls:
+			p.newline()
+			p.indented(child)
+		case child.Production == types.DeclarationProd:
+			p.newline()
+			p.node(child)
+		case child.Production == types.StatementProd:
+			p.newline()
+			p.indented(child)
+		case isTerminal(child, types.BeginKeyword), isTerminal(child, types.EndKeyword):
+			p.newline()
+			p.token(child)
+		default:
+			p.node(child)
+		}
+	}
+}
+
+// compound prints a record type or a compound statement.  Its fields,
+// statements or when clauses go on lines of their own, one level in,
+// and the end, else or closing brace on a line of its own.
+func (p *printer) compound(node *types.ParseNode) {
+	for i := range node.ChildNodes {
+		child := &node.ChildNodes[i]
+		switch {
+		case child.Production == types.StatementProd, child.Production == types.CaseAlternativeProd, child.Production == types.VariableDeclarationProd:
+			p.newline()
+			p.indented(child)
+		case isTerminal(child, types.EndKeyword), isTerminal(child, types.ElseKeyword), isTerminal(child, types.CloseCurlyBracket) && node.Production == types.RecordTypeProd:
+			p.newline()
+			p.token(child)
+		default:
+			p.node(child)
+		}
+	}
+}
+
+// indented prints node one level further in.
+func (p *printer) indented(node *types.ParseNode) {
+	p.indent++
+	p.node(node)
+	p.indent--
+}
+
+// token prints a terminal with the comments around it.
+func (p *printer) token(node *types.ParseNode) {
+	token := node.TerminalToken
+	// A block comment after the token before keeps a space after it
+	p.space = p.space || p.spaceBefore(token.TokenType)
+	p.glue = false
+	p.leading(token.LeadingTrivia)
+
+	// The parameter modes are keywords to the parser but identifiers
+	// to the scanner, so in the strict dialect their case matters
+	text := token.Lexeme
+	if isReserved(token) {
+		text = p.keyword(text)
+	}
+	p.write(text)
+	p.prev = token.TokenType
+	p.trailing(token.TrailingTrivia)
+}
+
+// keyword returns a keyword in the chosen case.
+func (p *printer) keyword(text string) string {
+	if p.options.KeywordCase == UpperCase {
+		return strings.ToUpper(text)
+	}
+	return strings.ToLower(text)
+}
+
+// spaceBefore reports whether a token of type tokenType is separated
+// from the token before it on the same line.
+func (p *printer) spaceBefore(tokenType types.TokenType) bool {
+	if p.glue {
+		// Two minus signs written together would read as a decrement
+		return tokenType == types.SubtractionOperator
+	}
+	switch tokenType {
+	case types.CommaSymbol, types.SemiColonSymbol, types.CloseRoundBracket, types.CloseSquareBracket, types.PeriodSymbol:
+		return false
+	case types.OpenRoundBracket:
+		// A call, or the parameters after a procedure's return type
+		switch p.prev {
+		case types.IdentifierToken, types.IntegerKeyword, types.FloatKeyword, types.StringKeyword, types.BoolKeyword:
+			return false
+		}
+	case types.OpenSquareBracket:
+		// Only an array literal is not an index or a bound
+		return p.prev == types.AssignmentOperator
+	}
+	switch p.prev {
+	case types.OpenRoundBracket, types.OpenSquareBracket, types.PeriodSymbol:
+		return false
+	}
+	return true
+}
+
+// leading prints the comments in front of a token.  A comment there
+// always began a line in the source, since the comments after the
+// token before on its line are part of that token's trailing trivia.
+func (p *printer) leading(trivia []types.Trivia) {
+	structural := p.lineStart
+	newlines, comments := 0, 0
+	for _, piece := range trivia {
+		switch piece.Kind {
+		case types.NewlineTrivia:
+			newlines++
+		case types.LineCommentTrivia, types.BlockCommentTrivia:
+			if comments > 0 && newlines == 0 {
+				// On the same line as the comment before
+				p.space = true
+			} else {
+				// Before the first comment every newline starts a
+				// blank line, after a comment the first ends it
+				p.ownLine(newlines > 1 || (comments == 0 && newlines > 0), structural)
+			}
+			p.write(strings.TrimRight(piece.Text, " \t\r"))
+			if piece.Kind == types.LineCommentTrivia {
+				p.breakLine = true
+			}
+			newlines = 0
+			comments++
+		}
+	}
+	switch {
+	case comments > 0 && newlines > 0:
+		p.ownLine(newlines > 1, structural)
+	case comments > 0:
+		p.space = true
+	case newlines > 0 && structural && p.started:
+		p.blank = true
+	}
+}
+
+// trailing prints the comments after a token on its line.
+func (p *printer) trailing(trivia []types.Trivia) {
+	for _, piece := range trivia {
+		switch piece.Kind {
+		case types.LineCommentTrivia:
+			p.space = true
+			p.write(strings.TrimRight(piece.Text, " \t\r"))
+			p.breakLine = true
+		case types.BlockCommentTrivia:
+			p.space = true
+			p.write(piece.Text)
+			p.space = true
+		}
+	}
+}
+
+// ownLine ends the current line, if anything is on it, so that what
+// comes next starts a line.  blank asks for an empty line in between.
+// Unless the layout was about to start a line anyway, structural is
+// false and the new line is a continuation.
+func (p *printer) ownLine(blank bool, structural bool) {
+	if !p.lineStart {
+		p.out.WriteString("\n")
+		p.lineStart = true
+		p.continued = !structural
+	}
+	p.breakLine = false
+	if blank && p.started {
+		p.blank = true
+	}
+}
+
+// newline ends the current line where the layout starts a new one.
+func (p *printer) newline() {
+	if !p.lineStart {
+		p.out.WriteString("\n")
+		p.lineStart = true
+	}
+	p.breakLine = false
+	p.continued = false
+	p.space = false
+}
+
+// write writes text, after the indentation if it starts a line.
+func (p *printer) write(text string) {
+	if p.breakLine {
+		p.ownLine(false, false)
+	}
+	if p.lineStart {
+		if p.blank {
+			p.out.WriteString("\n")
+		}
+		level := p.indent
+		if p.continued {
+			level++
+		}
+		p.out.WriteString(strings.Repeat(" ", level*p.options.IndentWidth))
+		p.lineStart = false
+	} else if p.space {
+		p.out.WriteString(" ")
+	}
+	p.out.WriteString(text)
+	p.space = false
+	p.blank = false
+	p.started = true
+}
+
+// isReserved reports whether the scanner found token to be a reserved
+// word, which is the same word in any case.
+func isReserved(token types.Token) bool {
+	tokenType, reserved := types.KeywordTokenTypeMap[strings.ToLower(token.Lexeme)]
+	return reserved && tokenType == token.TokenType
+}
+
+func isTerminal(node *types.ParseNode, tokenType types.TokenType) bool {
+	return len(node.ChildNodes) == 0 && node.TerminalToken.TokenType == tokenType
+}
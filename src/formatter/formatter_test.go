@@ -0,0 +1,184 @@
+package formatter
+
+import (
+	"compiler/src/scanner"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// format scans source with trivia and formats it.
+func format(t *testing.T, source string, options Options) string {
+	t.Helper()
+	lexer := scanner.NewLexerFromString(source, "test")
+	lexer.SetOptions(scanner.Options{KeepTrivia: true, CaseSensitive: options.CaseSensitive})
+	tokenList, err := lexer.ScanAll()
+	if err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+	text, err := Format(tokenList, options)
+	if err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+	return text
+}
+
+var lower = Options{IndentWidth: 4, KeywordCase: LowerCase}
+
+// messy is a program in no particular layout, with comments in the
+// places the formatter has to keep them.
+const messy = `// header
+PROGRAM Demo IS
+  Variable Total:integer; // running total
+  procedure Add:integer(variable N:integer inout)
+  /* body */ begin return N+1; end procedure;
+BEGIN
+  Total:=Add(-1)*2;   /* inline */
+  if(Total<3)then putString("BEGIN end"); // note: BEGIN
+  end if;
+
+
+  // last
+END PROGRAM.
+// trailer
+`
+
+func TestFormat(t *testing.T) {
+	want := `// header
+program Demo is
+variable Total : integer; // running total
+procedure Add : integer(variable N : integer inout)
+/* body */ begin
+    return N + 1;
+end procedure;
+begin
+    Total := Add(-1) * 2; /* inline */
+    if (Total < 3) then
+        putString("BEGIN end"); // note: BEGIN
+    end if;
+
+// last
+end program.
+// trailer
+`
+	if got := format(t, messy, lower); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestIdempotent formats each program twice, in every style.  The
+// second pass must change nothing.
+func TestIdempotent(t *testing.T) {
+	sources := []string{
+		messy,
+		"program p is begin end program.",
+		"program p is\n/* a */ /* b */\n\n\nvariable x : integer;\nbegin\nx := - -x; // c\nend program.\n",
+		"program p is\ntype t is record { variable a : integer; variable b : float[2]; };\nvariable x : integer[3] := [1, 2, 3];\nbegin\nfor (x[0] := 0; x[0] < 3) x[0] := x[0] + 1; end for;\nend program.\n",
+		"program p is\nvariable x : integer;\nbegin\nx := 1 // why\n+ 2;\nend program.\n",
+	}
+	styles := []Options{
+		lower,
+		{IndentWidth: 2, KeywordCase: UpperCase},
+		{IndentWidth: 4, KeywordCase: UpperCase, CaseSensitive: true},
+	}
+	for _, source := range sources {
+		for _, options := range styles {
+			once := format(t, source, options)
+			if twice := format(t, once, options); twice != once {
+				t.Errorf("%q with %+v: formatting again gave\n%s\nafter\n%s", source, options, twice, once)
+			}
+		}
+	}
+}
+
+// TestComments checks that comments keep their place: on a line of
+// their own before the token that followed them, or at the end of the
+// line of the token before them.
+func TestComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"program p is begin x := 1; // after\nend program.", "program p is\nbegin\n    x := 1; // after\nend program.\n"},
+		{"program p is begin\n// before\nx := 1; end program.", "program p is\nbegin\n    // before\n    x := 1;\nend program.\n"},
+		{"program p is begin x := /* in */ 1; end program.", "program p is\nbegin\n    x := /* in */ 1;\nend program.\n"},
+		{"program p is begin x := 1 // split\n+ 2; end program.", "program p is\nbegin\n    x := 1 // split\n        + 2;\nend program.\n"},
+		{"program p is begin\n/* one */ /* two */\nend program.", "program p is\nbegin\n/* one */ /* two */\nend program.\n"},
+		{"program p is begin end program. // end", "program p is\nbegin\nend program. // end\n"},
+		{"program p is begin end program.\n\n// last", "program p is\nbegin\nend program.\n\n// last\n"},
+	}
+	for _, test := range tests {
+		if got := format(t, test.source, lower); got != test.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", test.source, got, test.want)
+		}
+	}
+}
+
+// TestKeywordCase checks that only reserved words change case.  Names,
+// parameter modes, strings and comments are kept as written.
+func TestKeywordCase(t *testing.T) {
+	source := "Program Demo Is\nprocedure Swap : Bool(Variable X : integer inout)\nbegin return TRUE; end procedure;\nbegin\nputString(\"Begin\"); // End\nEnd Program.\n"
+	tests := []struct {
+		options Options
+		want    string
+	}{
+		{lower, "program Demo is\nprocedure Swap : bool(variable X : integer inout)\nbegin\n    return true;\nend procedure;\nbegin\n    putString(\"Begin\"); // End\nend program.\n"},
+		{Options{IndentWidth: 4, KeywordCase: UpperCase}, "PROGRAM Demo IS\nPROCEDURE Swap : BOOL(VARIABLE X : INTEGER inout)\nBEGIN\n    RETURN TRUE;\nEND PROCEDURE;\nBEGIN\n    putString(\"Begin\"); // End\nEND PROGRAM.\n"},
+		{Options{IndentWidth: 4, KeywordCase: UpperCase, CaseSensitive: true}, "PROGRAM Demo IS\nPROCEDURE Swap : BOOL(VARIABLE X : INTEGER inout)\nBEGIN\n    RETURN TRUE;\nEND PROCEDURE;\nBEGIN\n    putString(\"Begin\"); // End\nEND PROGRAM.\n"},
+	}
+	for _, test := range tests {
+		if got := format(t, source, test.options); got != test.want {
+			t.Errorf("%+v: got\n%s\nwant\n%s", test.options, got, test.want)
+		}
+	}
+}
+
+// TestCheck runs fmt -check on a formatted and an unformatted file.
+// It fails only on the unformatted one, and refuses to run with -w.
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "compiler")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = filepath.Join("..", "..")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	formatted := filepath.Join(dir, "formatted.src")
+	unformatted := filepath.Join(dir, "unformatted.src")
+	if err := os.WriteFile(formatted, []byte(format(t, messy, lower)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unformatted, []byte(messy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"-check", formatted}, 0, ""},
+		{[]string{"-check", unformatted}, 1, unformatted + "\n"},
+		{[]string{"-check", formatted, unformatted}, 1, unformatted + "\n"},
+		{[]string{"-check", "-w", formatted}, 2, "-check and -w cannot be used together\n"},
+	}
+	for _, test := range tests {
+		cmd := exec.Command(binary, append([]string{"fmt"}, test.args...)...)
+		out, err := cmd.CombinedOutput()
+		status := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.status || string(out) != test.output {
+			t.Errorf("fmt %s: exit status %d with output %q, want %d with %q", strings.Join(test.args, " "), status, out, test.status, test.output)
+		}
+	}
+	// -check must not have touched the file
+	if text, err := os.ReadFile(unformatted); err != nil || string(text) != messy {
+		t.Errorf("fmt -check changed %s", unformatted)
+	}
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"compiler/src/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type jsonParseNode struct {
+	Production types.ProductionType `json:"production"`
+	Span       types.Span           `json:"span"`
+	Token      *types.Token         `json:"token,omitempty"`
+	Children   []jsonParseNode      `json:"children,omitempty"`
+}
+
+func toJSONParseNode(node *types.ParseNode) jsonParseNode {
+	jsonNode := jsonParseNode{Production: node.Production, Span: node.Span()}
+	if node.TerminalToken.TokenType != "" {
+		token := node.TerminalToken
+		jsonNode.Token = &token
+	}
+	for i := range node.ChildNodes {
+		jsonNode.Children = append(jsonNode.Children, toJSONParseNode(&node.ChildNodes[i]))
+	}
+	return jsonNode
+}
+
+// WriteParseTreeJSON writes the tree rooted at node as indented JSON.
+func WriteParseTreeJSON(writer io.Writer, node *types.ParseNode) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toJSONParseNode(node))
+}
+
+// WriteParseTreeDOT writes the tree rooted at node as a Graphviz
+// digraph.  Terminal nodes show their token's lexeme.
+func WriteParseTreeDOT(writer io.Writer, node *types.ParseNode) error {
+	var dot strings.Builder
+	dot.WriteString("digraph ParseTree {\n")
+	dot.WriteString("    node [shape=box, fontname=\"monospace\"];\n")
+	nextID := 0
+	var writeNode func(node *types.ParseNode) int
+	writeNode = func(node *types.ParseNode) int {
+		id := nextID
+		nextID++
+		label := string(node.Production)
+		shape := ""
+		if node.TerminalToken.TokenType != "" {
+			label += "\n" + node.TerminalToken.Lexeme
+			shape = ", shape=ellipse"
+		}
+		fmt.Fprintf(&dot, "    n%d [label=%s%s];\n", id, dotQuote(label), shape)
+		for i := range node.ChildNodes {
+			childID := writeNode(&node.ChildNodes[i])
+			fmt.Fprintf(&dot, "    n%d -> n%d;\n", id, childID)
+		}
+		return id
+	}
+	writeNode(node)
+	dot.WriteString("}\n")
+
+	_, err := io.WriteString(writer, dot.String())
+	return err
+}
+
+// dotQuote quotes label as a DOT string.
+func dotQuote(label string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+	return "\"" + replacer.Replace(label) + "\""
+}
@@ -1,133 +1,102 @@
 // The entry point function is Parse
-// Parse setups the builtinsSymbolTable.
-// The function then calls parseProgram which begins
+// Parse creates a Parser which calls ParseProgram to begin
 // parsing the token list generated by the scanner.
-// The parser generates a parse tree.  Functions such as
+// The parser generates a parse tree.  Methods such as
 // ParseProcedureBody or ParseExpression are responsible
-// for generating a sub tree.
+// for generating a sub tree.  Parsing is purely syntactic: which
+// declaration a name refers to is left to the resolver.
 
 package parser
 
 import (
 	"compiler/src/types"
 	"errors"
-	"log"
-	"strconv"
 )
 
-var tokenIndex int = 0
-var tokenList []types.Token
-var currentToken types.Token
-var parseTreeRoot types.ParseNode
-var globalSymbolTable = map[string]types.STEntry{}
-var builtinSymbolTable = map[string]types.STEntry{}
-
-func Parse(tokenListArg []types.Token) types.ParseNode {
-	tokenList = tokenListArg
-
-	// Add builtin functions to globalSymbolTable
-	builtinSymbolTable["getbool"] = types.STEntry{Identifier: "getbool", EntryType: types.STProcedure, ProcedureReturnType: types.STVarBool}
-	builtinSymbolTable["getinteger"] = types.STEntry{Identifier: "getinteger", EntryType: types.STProcedure, ProcedureReturnType: types.STVarInteger}
-	builtinSymbolTable["getfloat"] = types.STEntry{Identifier: "getfloat", EntryType: types.STProcedure, ProcedureReturnType: types.STVarFloat}
-	builtinSymbolTable["getstring"] = types.STEntry{Identifier: "getstring", EntryType: types.STProcedure, ProcedureReturnType: types.STVarString}
-	stePutBool := types.STEntry{Identifier: "putbool", EntryType: types.STProcedure, ProcedureReturnType: types.STVarBool}
-	stePutBool.ProcedureArgTypes = append(stePutBool.ProcedureArgTypes, types.STVarBool)
-	builtinSymbolTable["putbool"] = stePutBool
-	stePutInteger := types.STEntry{Identifier: "putinteger", EntryType: types.STProcedure, ProcedureReturnType: types.STVarBool}
-	stePutInteger.ProcedureArgTypes = append(stePutInteger.ProcedureArgTypes, types.STVarInteger)
-	builtinSymbolTable["putinteger"] = stePutInteger
-	stePutFloat := types.STEntry{Identifier: "putfloat", EntryType: types.STProcedure, ProcedureReturnType: types.STVarBool}
-	stePutFloat.ProcedureArgTypes = append(stePutFloat.ProcedureArgTypes, types.STVarFloat)
-	builtinSymbolTable["putfloat"] = stePutFloat
-	stePutString := types.STEntry{Identifier: "putstring", EntryType: types.STProcedure, ProcedureReturnType: types.STVarBool}
-	stePutString.ProcedureArgTypes = append(stePutString.ProcedureArgTypes, types.STVarString)
-	builtinSymbolTable["putstring"] = stePutString
-	steSqrt := types.STEntry{Identifier: "sqrt", EntryType: types.STProcedure, ProcedureReturnType: types.STVarFloat}
-	steSqrt.ProcedureArgTypes = append(steSqrt.ProcedureArgTypes, types.STVarInteger)
-	builtinSymbolTable["sqrt"] = steSqrt
-
-	err := ParseProgram()
-	if err != nil {
-		log.Fatal(errors.New(err.Error() + "\nLine: " + strconv.Itoa(currentToken.LineNumber)))
-	}
-	return parseTreeRoot
+// Program is the result of parsing a token list.
+type Program struct {
+	ParseTree types.ParseNode
 }
 
-func PrintParseNodes(node *types.ParseNode, indent int) {
-	indentStr := ""
-	for i := 0; i < indent; i++ {
-		indentStr += "    "
-	}
-	str := (*node).TerminalToken.StringValue
-	println(indentStr, (*node).Production, "  |  ", (*node).TerminalToken.TokenType, "    ", str)
-	for _, val := range (*node).ChildNodes {
-		PrintParseNodes(&val, indent+1)
-	}
+// Parser holds all of the state for parsing one token list.
+// Separate Parsers share nothing and may be used concurrently.
+type Parser struct {
+	tokenIndex    int
+	tokenList     []types.Token
+	currentToken  types.Token
+	parseTreeRoot types.ParseNode
+	diagnostics   types.DiagnosticList
 }
 
-func GetNextToken() {
-	currentToken = tokenList[tokenIndex]
-	tokenIndex++
+// NewParser returns a Parser for tokenList.  An EOF token is added to
+// the end of tokenList if the scanner did not supply one.
+func NewParser(tokenList []types.Token) *Parser {
+	if len(tokenList) == 0 || tokenList[len(tokenList)-1].TokenType != types.EOFToken {
+		eofToken := types.Token{TokenType: types.EOFToken, IntValue: -1, FloatValue: -1}
+		if len(tokenList) > 0 {
+			last := tokenList[len(tokenList)-1].Span
+			eofToken.Span = types.Span{FileName: last.FileName, Start: last.End, End: last.End}
+		}
+		tokenList = append(tokenList[:len(tokenList):len(tokenList)], eofToken)
+	}
+	return &Parser{tokenList: tokenList}
 }
 
-func CheckTokenType(checkType types.TokenType) bool {
-	if currentToken.TokenType == checkType {
-		return true
-	}
-	return false
+// Parse parses tokenList with a new Parser.
+func Parse(tokenList []types.Token) (Program, error) {
+	return NewParser(tokenList).Parse()
 }
 
-func CheckLookAhead(checkType types.TokenType) bool {
-	if tokenList[tokenIndex].TokenType == checkType {
-		return true
+// Parse parses the whole token list.  A Parser can only be used once.
+// Parsing continues after a syntax error, so the error is a
+// types.DiagnosticList of every error found and the Program holds the
+// partial tree, with error nodes where constructs were skipped.
+func (p *Parser) Parse() (Program, error) {
+	err := p.ParseProgram()
+	if err != nil {
+		p.recordError(err)
 	}
-	return false
+	return Program{ParseTree: p.parseTreeRoot}, p.diagnostics.Err()
 }
 
-func AddSymbolTableEntry(makeGlobal bool, stEntry types.STEntry, localSymbolTable *map[string]types.STEntry) error {
-	strErrorGlobalExists := "\nError: This global symbol has already been declared"
-	strErrorLocalExists := "\nError: This local symbol has already been declared"
-	strErrorBuiltinExists := "\nError: Cannot overload builtin function"
-
-	_, builtinExists := builtinSymbolTable[stEntry.Identifier]
-	if builtinExists {
-		return errors.New(strErrorBuiltinExists)
+// GetNextToken advances to the next token.  The list always ends
+// with an EOF token, which GetNextToken never moves past.
+func (p *Parser) GetNextToken() {
+	if p.tokenIndex >= len(p.tokenList) {
+		return
 	}
+	p.currentToken = p.tokenList[p.tokenIndex]
+	p.tokenIndex++
+}
 
-	if makeGlobal {
-		_, exists := globalSymbolTable[stEntry.Identifier]
-		if exists {
-			return errors.New(strErrorGlobalExists)
-		} else {
-			globalSymbolTable[stEntry.Identifier] = stEntry
-		}
+func (p *Parser) CheckTokenType(checkType types.TokenType) bool {
+	if p.currentToken.TokenType == checkType {
+		return true
 	}
+	return false
+}
 
-	if localSymbolTable == nil {
-		return nil
+func (p *Parser) CheckLookAhead(checkType types.TokenType) bool {
+	if p.tokenIndex >= len(p.tokenList) {
+		return checkType == types.EOFToken
 	}
-
-	_, exists := (*localSymbolTable)[stEntry.Identifier]
-	if exists {
-		return errors.New(strErrorLocalExists)
-	} else {
-		(*localSymbolTable)[stEntry.Identifier] = stEntry
+	if p.tokenList[p.tokenIndex].TokenType == checkType {
+		return true
 	}
-
-	return nil
+	return false
 }
 
-func ParseProgram() error {
+func (p *Parser) ParseProgram() error {
 	node := types.ParseNode{Production: types.ProgramProd}
-	parseTreeRoot = node
+	p.parseTreeRoot = node
 	errString := "\nError parsing program"
 
-	err := ParseProgramHeader(&parseTreeRoot)
+	err := p.ParseProgramHeader(&p.parseTreeRoot)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	err = ParseProgramBody(&parseTreeRoot)
+	err = p.ParseProgramBody(&p.parseTreeRoot)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
@@ -135,109 +104,76 @@ func ParseProgram() error {
 	return nil
 }
 
-func IdentifierProd(parentNode *types.ParseNode) error {
+func (p *Parser) IdentifierProd(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseProgramHeader(parentNode *types.ParseNode) error {
+func (p *Parser) ParseProgramHeader(parentNode *types.ParseNode) error {
 	programHeaderNode := types.ParseNode{Production: types.ProgramHeaderProd}
 	errString := "\nError parsing program header"
 
-	GetNextToken()
-	if !CheckTokenType(types.ProgramKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ProgramKeyword) {
+		return errors.New(errString + p.expected(types.ProgramKeyword))
 	}
-	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.IdentifierToken) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
-	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
+	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.IsKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IsKeyword) {
+		return errors.New(errString + p.expected(types.IsKeyword))
 	}
-	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	programHeaderNode.ChildNodes = append(programHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, programHeaderNode)
 
 	return nil
 }
 
-func ParseProgramBody(parentNode *types.ParseNode) error {
+func (p *Parser) ParseProgramBody(parentNode *types.ParseNode) error {
 	programBodyNode := types.ParseNode{Production: types.ProgramBodyProd}
 	errString := "\nError parsing program body"
 
-	cont := true
-	var err error
-
-	GetNextToken()
-	for cont { //TODO: replace cont with true
-		if CheckTokenType(types.BeginKeyword) {
-			// append begin node
-			beginNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
-			programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, beginNode)
-			break
-		}
-		cont, err = ParseDeclaration(&programBodyNode, true, nil)
-
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	p.GetNextToken()
+	err := p.ParseDeclarationList(&programBodyNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
+	// append begin node
+	beginNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
+	programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, beginNode)
 
-	GetNextToken()
+	p.GetNextToken()
 	// Parse Statements here
-	for {
-		if CheckTokenType(types.EndKeyword) {
-			break
-		}
-		emptyLocalSymbolTable := map[string]types.STEntry{}
-		err = ParseStatement(&programBodyNode, &(emptyLocalSymbolTable))
-
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		GetNextToken()
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	err = p.ParseStatementList(&programBodyNode, types.EndKeyword)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
 	// Parse Program footer here
-	if !CheckTokenType(types.EndKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
-	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, endKeywordNode)
 
-	GetNextToken()
-	if !CheckTokenType(types.ProgramKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ProgramKeyword) {
+		return errors.New(errString + p.expected(types.ProgramKeyword))
 	}
-	progKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	progKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, progKeywordNode)
 
-	GetNextToken()
-	if !CheckTokenType(types.PeriodSymbol) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.PeriodSymbol) {
+		return errors.New(errString + p.expected(types.PeriodSymbol))
 	}
-	periodNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
+	periodNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken}
 	programBodyNode.ChildNodes = append(programBodyNode.ChildNodes, periodNode)
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, programBodyNode)
@@ -245,31 +181,112 @@ func ParseProgramBody(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseDeclaration(parentNode *types.ParseNode, makeGlobal bool, localSymbolTable *map[string]types.STEntry) (bool, error) {
+// ParseDeclarationList parses declarations, each followed by a
+// semicolon, up to the begin keyword, which is left as the current
+// token.  A declaration that fails to parse is recorded and skipped.
+func (p *Parser) ParseDeclarationList(parentNode *types.ParseNode) error {
+	errString := "\nError parsing declaration list"
+
+	for !p.CheckTokenType(types.BeginKeyword) {
+		startIndex := p.tokenIndex - 1
+		_, err := p.ParseDeclaration(parentNode)
+		if err != nil {
+			p.recordError(errors.New(errString + err.Error()))
+			err = p.skipDeclaration(parentNode, startIndex)
+			if err != nil {
+				return errors.New(errString + err.Error())
+			}
+		} else if !p.CheckTokenType(types.SemiColonSymbol) {
+			p.recordError(errors.New(errString + p.expected(types.SemiColonSymbol)))
+			if !p.checkAny(declarationStops) {
+				err = p.skipDeclaration(parentNode, p.tokenIndex-1)
+				if err != nil {
+					return errors.New(errString + err.Error())
+				}
+			}
+		}
+
+		if p.CheckTokenType(types.SemiColonSymbol) {
+			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken}
+			(*parentNode).ChildNodes = append((*parentNode).ChildNodes, semiColonNode)
+			p.GetNextToken()
+		}
+	}
+
+	return nil
+}
+
+// ParseStatementList parses statements, each followed by a semicolon,
+// up to one of the terminators, which is left as the current token.
+// A statement that fails to parse is recorded and skipped.
+func (p *Parser) ParseStatementList(parentNode *types.ParseNode, terminators ...types.TokenType) error {
+	errString := "\nError parsing statement list"
+
+	for !p.checkAny(terminators) {
+		startIndex := p.tokenIndex - 1
+		err := p.ParseStatement(parentNode)
+		if err != nil {
+			p.recordError(errors.New(errString + err.Error()))
+			err = p.skipStatement(parentNode, startIndex)
+			if err != nil {
+				return errors.New(errString + err.Error())
+			}
+		} else {
+			p.GetNextToken()
+			if !p.CheckTokenType(types.SemiColonSymbol) {
+				p.recordError(errors.New(errString + p.expected(types.SemiColonSymbol)))
+				if !p.checkAny(terminators) && !p.isStatementStart() {
+					err = p.skipStatement(parentNode, p.tokenIndex-1)
+					if err != nil {
+						return errors.New(errString + err.Error())
+					}
+				}
+			}
+		}
+
+		if p.CheckTokenType(types.SemiColonSymbol) {
+			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken}
+			(*parentNode).ChildNodes = append((*parentNode).ChildNodes, semiColonNode)
+			p.GetNextToken()
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) ParseDeclaration(parentNode *types.ParseNode) (bool, error) {
 	declarationNode := types.ParseNode{Production: types.DeclarationProd}
 	errString := "\nError parsing declaration"
-	thisMakeGlobal := makeGlobal
 
-	// GetNextToken()
-	if CheckTokenType(types.GlobalKeyword) {
-		thisMakeGlobal = true
-		globalNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	// p.GetNextToken()
+	if p.CheckTokenType(types.GlobalKeyword) {
+		globalNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 		declarationNode.ChildNodes = append(declarationNode.ChildNodes, globalNode)
-		GetNextToken()
+		p.GetNextToken()
 	}
 
-	if CheckTokenType(types.ProcedureKeyword) {
-		err := ParseProcedureDeclaration(&declarationNode, thisMakeGlobal, localSymbolTable)
+	if p.CheckTokenType(types.ProcedureKeyword) {
+		err := p.ParseProcedureDeclaration(&declarationNode)
+		if err != nil {
+			return false, errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.VariableKeyword) {
+		err := p.ParseVariableDeclaration(&declarationNode)
 		if err != nil {
 			return false, errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.VariableKeyword) {
-		err := ParseVariableDeclaration(&declarationNode, thisMakeGlobal, localSymbolTable)
+	} else if p.CheckTokenType(types.TypeKeyword) {
+		err := p.ParseTypeDeclaration(&declarationNode)
+		if err != nil {
+			return false, errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.ConstantKeyword) {
+		err := p.ParseConstantDeclaration(&declarationNode)
 		if err != nil {
 			return false, errors.New(errString + err.Error())
 		}
 	} else {
-		return false, errors.New(errString)
+		return false, errors.New(errString + p.expected(types.ProcedureKeyword, types.VariableKeyword, types.TypeKeyword, types.ConstantKeyword))
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, declarationNode)
@@ -277,163 +294,112 @@ func ParseDeclaration(parentNode *types.ParseNode, makeGlobal bool, localSymbolT
 	return true, nil
 }
 
-func ParseProcedureDeclaration(parentNode *types.ParseNode, makeGlobal bool, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseProcedureDeclaration(parentNode *types.ParseNode) error {
 	procDecNode := types.ParseNode{Production: types.ProcedureDeclarationProd}
 	errString := "\nError parsing procedure declaration"
-	thisMakeGlobal := makeGlobal
-	procLocalSymbolTable := map[string]types.STEntry{}
 
-	err := ParseProcedureHeader(&procDecNode, thisMakeGlobal, localSymbolTable, &procLocalSymbolTable)
+	err := p.ParseProcedureHeader(&procDecNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	err = ParseProcedureBody(&procDecNode, &procLocalSymbolTable)
+	err = p.ParseProcedureBody(&procDecNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
-	GetNextToken()
-
-	procDecNode.ProcLocalSymbolTable = procLocalSymbolTable
+	p.GetNextToken()
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, procDecNode)
 
 	return nil
 }
 
-func ParseProcedureHeader(parentNode *types.ParseNode, makeGlobal bool, localSymbolTable *map[string]types.STEntry, procLocalSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseProcedureHeader(parentNode *types.ParseNode) error {
 	procHeaderNode := types.ParseNode{Production: types.ProcedureHeaderProd}
 	errString := "\nError parsing procedure header"
-	thisMakeGlobal := makeGlobal
-	procHeaderSTEntry := types.STEntry{}
-	procHeaderSTEntry.EntryType = types.STProcedure
 
-	if !CheckTokenType(types.ProcedureKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.ProcedureKeyword) {
+		return errors.New(errString + p.expected(types.ProcedureKeyword))
 	}
-	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.IdentifierToken) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
-	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
-	procHeaderSTEntry.Identifier = currentToken.StringValue
+	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.ColonSymbol) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ColonSymbol) {
+		return errors.New(errString + p.expected(types.ColonSymbol))
 	}
-	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if CheckTokenType(types.IntegerKeyword) ||
-		CheckTokenType(types.FloatKeyword) ||
-		CheckTokenType(types.StringKeyword) ||
-		CheckTokenType(types.BoolKeyword) {
-		procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
+	p.GetNextToken()
+	err := p.ParseTypeMark(&procHeaderNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
-	procHeaderSTEntry.ProcedureReturnType = types.STType(currentToken.TokenType)
 
-	GetNextToken()
-	if !CheckTokenType(types.OpenRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
-	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if CheckTokenType(types.CloseRoundBracket) {
-		procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	p.GetNextToken()
+	if p.CheckTokenType(types.CloseRoundBracket) {
+		procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 	} else {
 		// Parse parameter list
-		err := ParseParameterList(&procHeaderNode, false, &procHeaderSTEntry, procLocalSymbolTable)
+		err := p.ParseParameterList(&procHeaderNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
 
-		// GetNextToken()
-		if CheckTokenType(types.CloseRoundBracket) {
-			procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+		// p.GetNextToken()
+		if p.CheckTokenType(types.CloseRoundBracket) {
+			procHeaderNode.ChildNodes = append(procHeaderNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 		}
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, procHeaderNode)
-	err := AddSymbolTableEntry(thisMakeGlobal, procHeaderSTEntry, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
-	err = AddSymbolTableEntry(false, procHeaderSTEntry, procLocalSymbolTable) // Allows for procedure to be seen locally for recursive calls
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
 
 	return nil
 }
 
-func ParseProcedureBody(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseProcedureBody(parentNode *types.ParseNode) error {
 	procBodyNode := types.ParseNode{Production: types.ProcedureBodyProd}
 	errString := "\nError parsing procedure body"
 
-	cont := true
-	var err error
-
-	GetNextToken()
-	for cont {
-		if CheckTokenType(types.BeginKeyword) {
-			// append begin node
-			beginNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
-			procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, beginNode)
-			break
-		}
-		cont, err = ParseDeclaration(&procBodyNode, false, localSymbolTable)
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	p.GetNextToken()
+	err := p.ParseDeclarationList(&procBodyNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
+	// append begin node
+	beginNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
+	procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, beginNode)
 
-	GetNextToken()
+	p.GetNextToken()
 	// Parse Statements here
-	for {
-		if CheckTokenType(types.EndKeyword) {
-			break
-		}
-		err = ParseStatement(&procBodyNode, localSymbolTable)
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		GetNextToken()
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	err = p.ParseStatementList(&procBodyNode, types.EndKeyword)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
 	// Parse procedure footer here
-	if !CheckTokenType(types.EndKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
-	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, endKeywordNode)
 
-	GetNextToken()
-	if !CheckTokenType(types.ProcedureKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ProcedureKeyword) {
+		return errors.New(errString + p.expected(types.ProcedureKeyword))
 	}
-	procKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	procKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	procBodyNode.ChildNodes = append(procBodyNode.ChildNodes, procKeywordNode)
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, procBodyNode)
@@ -441,46 +407,41 @@ func ParseProcedureBody(parentNode *types.ParseNode, localSymbolTable *map[strin
 	return nil
 }
 
-func ParseParameterList(parentNode *types.ParseNode, makeGlobal bool, procHeaderSTEntry *types.STEntry, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseParameterList(parentNode *types.ParseNode) error {
 	paramListNode := types.ParseNode{Production: types.ParamaterListProd}
 	errString := "\nError parsing parameter list"
 
 	for {
-		err := ParseParameter(&paramListNode, false, procHeaderSTEntry, localSymbolTable)
+		err := p.ParseParameter(&paramListNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-		if CheckTokenType(types.CommaSymbol) {
-			paramListNode.ChildNodes = append(paramListNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-			GetNextToken()
-		} else if CheckTokenType(types.CloseRoundBracket) {
+		if p.CheckTokenType(types.CommaSymbol) {
+			paramListNode.ChildNodes = append(paramListNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+			p.GetNextToken()
+		} else if p.CheckTokenType(types.CloseRoundBracket) {
 			(*parentNode).ChildNodes = append((*parentNode).ChildNodes, paramListNode)
 			return nil
 		} else {
-			return errors.New(errString)
+			return errors.New(errString + p.expected(types.CommaSymbol, types.CloseRoundBracket))
 		}
 	}
-	return nil
 }
 
-func ParseParameter(parentNode *types.ParseNode, makeGlobal bool, procHeaderSTEntry *types.STEntry, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseParameter(parentNode *types.ParseNode) error {
 	paramNode := types.ParseNode{Production: types.ParamaterProd}
 	errString := "\nError parsing parameter"
 
-	err := ParseVariableDeclaration(&paramNode, false, localSymbolTable)
+	err := p.ParseVariableDeclaration(&paramNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	nodes := paramNode.ChildNodes[0].ChildNodes
-	if len(nodes) == 4 {
-		procHeaderSTEntry.ProcedureArgTypes = append(
-			procHeaderSTEntry.ProcedureArgTypes,
-			types.STType(nodes[3].TerminalToken.TokenType))
-	} else if len(nodes) == 7 {
-		procHeaderSTEntry.ProcedureArgTypes = append(
-			procHeaderSTEntry.ProcedureArgTypes,
-			types.STType(nodes[3].TerminalToken.TokenType+"_array"))
+	// An optional mode follows the declaration; without one the
+	// argument is passed in
+	if p.CheckParamMode() {
+		paramNode.ChildNodes = append(paramNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, paramNode)
@@ -488,87 +449,330 @@ func ParseParameter(parentNode *types.ParseNode, makeGlobal bool, procHeaderSTEn
 	return nil
 }
 
-func ParseVariableDeclaration(parentNode *types.ParseNode, makeGlobal bool, localSymbolTable *map[string]types.STEntry) error {
+// CheckParamMode reports whether the current token is a parameter
+// mode.  Modes are scanned as identifiers, so in, out and inout stay
+// usable as names elsewhere.
+func (p *Parser) CheckParamMode() bool {
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return false
+	}
+	switch types.ParamMode(p.currentToken.StringValue) {
+	case types.InMode, types.OutMode, types.InoutMode:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) ParseVariableDeclaration(parentNode *types.ParseNode) error {
 	varDecNode := types.ParseNode{Production: types.VariableDeclarationProd}
 	errString := "\nError parsing variable declaration"
-	thisMakeGlobal := makeGlobal
-	varDecSTEntry := types.STEntry{}
 
-	if !CheckTokenType(types.VariableKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.VariableKeyword) {
+		return errors.New(errString + p.expected(types.VariableKeyword))
 	}
-	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.IdentifierToken) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
-	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
-	varDecSTEntry.Identifier = currentToken.StringValue
+	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.ColonSymbol) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ColonSymbol) {
+		return errors.New(errString + p.expected(types.ColonSymbol))
 	}
-	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if CheckTokenType(types.IntegerKeyword) ||
-		CheckTokenType(types.FloatKeyword) ||
-		CheckTokenType(types.StringKeyword) ||
-		CheckTokenType(types.BoolKeyword) {
-		varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
+	p.GetNextToken()
+	err := p.ParseTypeMark(&varDecNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
-	varDecSTEntry.EntryType = types.STType(currentToken.TokenType)
 
-	GetNextToken()
-	if CheckTokenType(types.OpenSquareBracket) {
-		varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-		varDecSTEntry.EntryType = types.STType(varDecSTEntry.EntryType + "_array")
-		varDecSTEntry.IsArray = true
-	} else {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, varDecNode)
-		err := AddSymbolTableEntry(thisMakeGlobal, varDecSTEntry, localSymbolTable)
+	p.GetNextToken()
+	if p.CheckTokenType(types.OpenSquareBracket) {
+		varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+		p.GetNextToken()
+		err = p.ParseBound(&varDecNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-		return nil
+
+		p.GetNextToken()
+		if p.CheckTokenType(types.CloseSquareBracket) {
+			varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+		} else {
+			return errors.New(errString + p.expected(types.CloseSquareBracket))
+		}
+
+		p.GetNextToken()
 	}
 
-	GetNextToken()
-	if CheckTokenType(types.IntegerToken) {
-		err := ParseBound(&varDecNode)
+	if p.CheckTokenType(types.AssignmentOperator) {
+		err = p.ParseInitializer(&varDecNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
+
+		p.GetNextToken()
 	}
 
-	varDecSTEntry.ArraySize = int(varDecNode.ChildNodes[5].ChildNodes[0].TerminalToken.IntValue)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, varDecNode)
+	return nil
+}
+
+// ParseInitializer parses ":= expression" or ":= [expression, ...]",
+// the initial value of a variable.
+func (p *Parser) ParseInitializer(parentNode *types.ParseNode) error {
+	initializerNode := types.ParseNode{Production: types.InitializerProd}
+	errString := "\nError parsing initializer"
+
+	if !p.CheckTokenType(types.AssignmentOperator) {
+		return errors.New(errString + p.expected(types.AssignmentOperator))
+	}
+	initializerNode.ChildNodes = append(initializerNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if CheckTokenType(types.CloseSquareBracket) {
-		varDecNode.ChildNodes = append(varDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	p.GetNextToken()
+	var err error
+	if p.CheckTokenType(types.OpenSquareBracket) {
+		err = p.ParseArrayLiteral(&initializerNode)
 	} else {
-		return errors.New(errString)
+		err = p.ParseExpression(&initializerNode)
+	}
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
-	GetNextToken()
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, initializerNode)
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, varDecNode)
-	err := AddSymbolTableEntry(thisMakeGlobal, varDecSTEntry, localSymbolTable)
+	return nil
+}
+
+// ParseArrayLiteral parses "[ expression, ... ]".
+func (p *Parser) ParseArrayLiteral(parentNode *types.ParseNode) error {
+	literalNode := types.ParseNode{Production: types.ArrayLiteralProd}
+	errString := "\nError parsing array literal"
+
+	if !p.CheckTokenType(types.OpenSquareBracket) {
+		return errors.New(errString + p.expected(types.OpenSquareBracket))
+	}
+	literalNode.ChildNodes = append(literalNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	for {
+		p.GetNextToken()
+		err := p.ParseExpression(&literalNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+
+		p.GetNextToken()
+		if p.CheckTokenType(types.CloseSquareBracket) {
+			break
+		}
+		if !p.CheckTokenType(types.CommaSymbol) {
+			return errors.New(errString + p.expected(types.CommaSymbol, types.CloseSquareBracket))
+		}
+		literalNode.ChildNodes = append(literalNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+	}
+	literalNode.ChildNodes = append(literalNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, literalNode)
+
+	return nil
+}
+
+// ParseConstantDeclaration parses "constant identifier : type mark
+// := expression".  The expression is evaluated during semantic
+// analysis.
+func (p *Parser) ParseConstantDeclaration(parentNode *types.ParseNode) error {
+	constDecNode := types.ParseNode{Production: types.ConstantDeclarationProd}
+	errString := "\nError parsing constant declaration"
+
+	if !p.CheckTokenType(types.ConstantKeyword) {
+		return errors.New(errString + p.expected(types.ConstantKeyword))
+	}
+	constDecNode.ChildNodes = append(constDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
+	}
+	constDecNode.ChildNodes = append(constDecNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ColonSymbol) {
+		return errors.New(errString + p.expected(types.ColonSymbol))
+	}
+	constDecNode.ChildNodes = append(constDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	err := p.ParseTypeMark(&constDecNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
+	}
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.AssignmentOperator) {
+		return errors.New(errString + p.expected(types.AssignmentOperator))
+	}
+	constDecNode.ChildNodes = append(constDecNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	err = p.ParseExpression(&constDecNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
+	}
+
+	p.GetNextToken()
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, constDecNode)
+
+	return nil
+}
+
+// ParseTypeMark parses one of the builtin type keywords or the name of
+// a type, which the resolver looks up.
+func (p *Parser) ParseTypeMark(parentNode *types.ParseNode) error {
+	errString := "\nError parsing type mark"
+
+	if p.CheckTokenType(types.IntegerKeyword) ||
+		p.CheckTokenType(types.FloatKeyword) ||
+		p.CheckTokenType(types.StringKeyword) ||
+		p.CheckTokenType(types.BoolKeyword) {
+		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+		return nil
+	}
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expectedWhat("a type"))
+	}
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, types.ParseNode{Production: types.TypeMarkProd, TerminalToken: p.currentToken})
+
+	return nil
+}
+
+func (p *Parser) ParseTypeDeclaration(parentNode *types.ParseNode) error {
+	typeDecNode := types.ParseNode{Production: types.TypeDeclarationProd}
+	errString := "\nError parsing type declaration"
+
+	if !p.CheckTokenType(types.TypeKeyword) {
+		return errors.New(errString + p.expected(types.TypeKeyword))
+	}
+	typeDecNode.ChildNodes = append(typeDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
+	}
+	typeDecNode.ChildNodes = append(typeDecNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IsKeyword) {
+		return errors.New(errString + p.expected(types.IsKeyword))
+	}
+	typeDecNode.ChildNodes = append(typeDecNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	var err error
+	if p.CheckTokenType(types.EnumKeyword) {
+		err = p.ParseEnumType(&typeDecNode)
+	} else if p.CheckTokenType(types.RecordKeyword) {
+		err = p.ParseRecordType(&typeDecNode)
+	} else {
+		err = errors.New(p.expected(types.EnumKeyword, types.RecordKeyword))
+	}
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
+
+	p.GetNextToken()
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, typeDecNode)
+
+	return nil
+}
+
+// ParseRecordType parses "record { variable declarations }".
+func (p *Parser) ParseRecordType(parentNode *types.ParseNode) error {
+	recordNode := types.ParseNode{Production: types.RecordTypeProd}
+	errString := "\nError parsing record type"
+
+	if !p.CheckTokenType(types.RecordKeyword) {
+		return errors.New(errString + p.expected(types.RecordKeyword))
+	}
+	recordNode.ChildNodes = append(recordNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenCurlyBracket) {
+		return errors.New(errString + p.expected(types.OpenCurlyBracket))
+	}
+	recordNode.ChildNodes = append(recordNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	for !p.CheckTokenType(types.CloseCurlyBracket) {
+		err := p.ParseVariableDeclaration(&recordNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+
+		if !p.CheckTokenType(types.SemiColonSymbol) {
+			return errors.New(errString + p.expected(types.SemiColonSymbol))
+		}
+		recordNode.ChildNodes = append(recordNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
+	}
+	recordNode.ChildNodes = append(recordNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, recordNode)
+
 	return nil
 }
 
-func ParseBound(parentNode *types.ParseNode) error {
+// ParseEnumType parses "enum { value, ... }".
+func (p *Parser) ParseEnumType(parentNode *types.ParseNode) error {
+	enumNode := types.ParseNode{Production: types.EnumTypeProd}
+	errString := "\nError parsing enum type"
+
+	if !p.CheckTokenType(types.EnumKeyword) {
+		return errors.New(errString + p.expected(types.EnumKeyword))
+	}
+	enumNode.ChildNodes = append(enumNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenCurlyBracket) {
+		return errors.New(errString + p.expected(types.OpenCurlyBracket))
+	}
+	enumNode.ChildNodes = append(enumNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	for {
+		p.GetNextToken()
+		if !p.CheckTokenType(types.IdentifierToken) {
+			return errors.New(errString + p.expected(types.IdentifierToken))
+		}
+		enumNode.ChildNodes = append(enumNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
+
+		p.GetNextToken()
+		if p.CheckTokenType(types.CloseCurlyBracket) {
+			break
+		}
+		if !p.CheckTokenType(types.CommaSymbol) {
+			return errors.New(errString + p.expected(types.CommaSymbol, types.CloseCurlyBracket))
+		}
+		enumNode.ChildNodes = append(enumNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+	}
+	enumNode.ChildNodes = append(enumNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, enumNode)
+
+	return nil
+}
+
+// ParseBound parses the length of an array, which may be any
+// expression that semantic analysis can evaluate, such as a constant.
+func (p *Parser) ParseBound(parentNode *types.ParseNode) error {
 	boundNode := types.ParseNode{Production: types.BoundProd}
 	errString := "\nError parsing bound"
 
-	err := ParseInteger(&boundNode)
+	err := p.ParseExpression(&boundNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
@@ -578,12 +782,12 @@ func ParseBound(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseInteger(parentNode *types.ParseNode) error {
-	integerNode := types.ParseNode{Production: types.NumberProd, TerminalToken: currentToken}
+func (p *Parser) ParseInteger(parentNode *types.ParseNode) error {
+	integerNode := types.ParseNode{Production: types.NumberProd, TerminalToken: p.currentToken}
 	errString := "\nError parsing number"
 
-	if !CheckTokenType(types.IntegerToken) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.IntegerToken) {
+		return errors.New(errString + p.expected(types.IntegerToken))
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, integerNode)
@@ -591,12 +795,12 @@ func ParseInteger(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseFloat(parentNode *types.ParseNode) error {
-	floatNode := types.ParseNode{Production: types.NumberProd, TerminalToken: currentToken}
+func (p *Parser) ParseFloat(parentNode *types.ParseNode) error {
+	floatNode := types.ParseNode{Production: types.NumberProd, TerminalToken: p.currentToken}
 	errString := "\nError parsing number"
 
-	if !CheckTokenType(types.FloatToken) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.FloatToken) {
+		return errors.New(errString + p.expected(types.FloatToken))
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, floatNode)
@@ -604,12 +808,12 @@ func ParseFloat(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseString(parentNode *types.ParseNode) error {
-	stringNode := types.ParseNode{Production: types.StringProd, TerminalToken: currentToken}
+func (p *Parser) ParseString(parentNode *types.ParseNode) error {
+	stringNode := types.ParseNode{Production: types.StringProd, TerminalToken: p.currentToken}
 	errString := "\nError parsing string"
 
-	if !CheckTokenType(types.StringToken) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.StringToken) {
+		return errors.New(errString + p.expected(types.StringToken))
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, stringNode)
@@ -617,121 +821,76 @@ func ParseString(parentNode *types.ParseNode) error {
 	return nil
 }
 
-func ParseStatement(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseStatement(parentNode *types.ParseNode) error {
 	statementNode := types.ParseNode{Production: types.StatementProd}
 	errString := "\nError parsing statement"
 
-	if CheckIfIdentifierExists(localSymbolTable) {
-		err := ParseAssignmentStatement(&statementNode, localSymbolTable)
+	if p.CheckTokenType(types.IdentifierToken) && p.CheckLookAhead(types.OpenRoundBracket) {
+		// A procedure call whose result is discarded
+		err := p.ParseProcedureCall(&statementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.IfKeyword) {
-		err := ParseIfStatement(&statementNode, localSymbolTable)
+	} else if p.CheckTokenType(types.IdentifierToken) {
+		err := p.ParseAssignmentStatement(&statementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.ForKeyword) {
-		err := ParseLoopStatement(&statementNode, localSymbolTable)
+	} else if p.CheckTokenType(types.IfKeyword) {
+		err := p.ParseIfStatement(&statementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.ReturnKeyword) {
-		err := ParseReturnStatement(&statementNode, localSymbolTable)
+	} else if p.CheckTokenType(types.ForKeyword) {
+		err := p.ParseLoopStatement(&statementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else {
-		return errors.New(errString)
-	}
-
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, statementNode)
-
-	return nil
-}
-
-func CheckIfIdentifierExists(localSymbolTable *map[string]types.STEntry) bool {
-	return CheckIfIdentifierExists_Local(localSymbolTable) || CheckIfIdentifierExists_Global() || CheckIfIdentifierExists_Builtin()
-}
-
-func CheckIfIdentifierExists_Local(localSymbolTable *map[string]types.STEntry) bool {
-	if localSymbolTable != nil {
-		_, exists := (*localSymbolTable)[currentToken.StringValue]
-		if exists {
-			return true
-		} else {
-			return false
+	} else if p.CheckTokenType(types.WhileKeyword) {
+		err := p.ParseWhileStatement(&statementNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.CaseKeyword) {
+		err := p.ParseCaseStatement(&statementNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.ReturnKeyword) {
+		err := p.ParseReturnStatement(&statementNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
 		}
+	} else if p.CheckTokenType(types.ExitKeyword) {
+		statementNode.ChildNodes = append(statementNode.ChildNodes, types.ParseNode{Production: types.ExitStatementProd, ChildNodes: []types.ParseNode{{Production: types.KeywordTerminal, TerminalToken: p.currentToken}}})
+	} else if p.CheckTokenType(types.ContinueKeyword) {
+		statementNode.ChildNodes = append(statementNode.ChildNodes, types.ParseNode{Production: types.ContinueStatementProd, ChildNodes: []types.ParseNode{{Production: types.KeywordTerminal, TerminalToken: p.currentToken}}})
 	} else {
-		return false
-	}
-}
-
-func CheckIfIdentifierExists_Global() bool {
-	// localGST := globalSymbolTable
-	// localGST["b"] = localGST["b"]
-	// ct := currentToken
-	// println(ct.StringValue)
-	_, exists := globalSymbolTable[currentToken.StringValue]
-	if exists {
-		return true
-	} else {
-		return false
-	}
-}
-
-func CheckIfIdentifierExists_Builtin() bool {
-	_, exists := builtinSymbolTable[currentToken.StringValue]
-	if exists {
-		return true
-	} else {
-		return false
+		return errors.New(errString + p.expectedWhat("a statement"))
 	}
-}
 
-func CheckIfIdentifierIsArray(localSymbolTable *map[string]types.STEntry) (bool, error) {
-	errString := "\nError identifier not declared"
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, statementNode)
 
-	if CheckIfIdentifierExists_Local(localSymbolTable) {
-		if (*localSymbolTable)[currentToken.StringValue].EntryType == types.STVarIntegerArray ||
-			(*localSymbolTable)[currentToken.StringValue].EntryType == types.STVarFloatArray ||
-			(*localSymbolTable)[currentToken.StringValue].EntryType == types.STVarStringArray ||
-			(*localSymbolTable)[currentToken.StringValue].EntryType == types.STVarBoolArray {
-			return true, nil
-		} else {
-			return false, nil
-		}
-	} else if CheckIfIdentifierExists_Global() {
-		if globalSymbolTable[currentToken.StringValue].EntryType == types.STVarIntegerArray ||
-			globalSymbolTable[currentToken.StringValue].EntryType == types.STVarFloatArray ||
-			globalSymbolTable[currentToken.StringValue].EntryType == types.STVarStringArray ||
-			globalSymbolTable[currentToken.StringValue].EntryType == types.STVarBoolArray {
-			return true, nil
-		} else {
-			return false, nil
-		}
-	} else {
-		return false, errors.New(errString)
-	}
+	return nil
 }
 
-func ParseAssignmentStatement(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseAssignmentStatement(parentNode *types.ParseNode) error {
 	assignmentStatementNode := types.ParseNode{Production: types.AssignmentStatementProd}
 	errString := "\nError parsing assignment statement"
 
-	err := ParseDestination(&assignmentStatementNode, localSymbolTable)
+	err := p.ParseDestination(&assignmentStatementNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	GetNextToken()
-	if !CheckTokenType(types.AssignmentOperator) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.AssignmentOperator) {
+		return errors.New(errString + p.expected(types.AssignmentOperator))
 	}
-	assignmentStatementNode.ChildNodes = append(assignmentStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	assignmentStatementNode.ChildNodes = append(assignmentStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	err = ParseExpression(&assignmentStatementNode, localSymbolTable) //, types.STVarInteger)
+	p.GetNextToken()
+	err = p.ParseExpression(&assignmentStatementNode) //, types.STVarInteger)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
@@ -741,93 +900,66 @@ func ParseAssignmentStatement(parentNode *types.ParseNode, localSymbolTable *map
 	return nil
 }
 
-func ParseIfStatement(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseIfStatement(parentNode *types.ParseNode) error {
 	ifStatementNode := types.ParseNode{Production: types.IfStatementProd}
 	errString := "\nError parsing if statement"
 
-	if !CheckTokenType(types.IfKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.IfKeyword) {
+		return errors.New(errString + p.expected(types.IfKeyword))
 	}
-	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.OpenRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
-	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	err := ParseExpression(&ifStatementNode, localSymbolTable) //, types.STVarBool)
+	p.GetNextToken()
+	err := p.ParseExpression(&ifStatementNode) //, types.STVarBool)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	GetNextToken()
-	if !CheckTokenType(types.CloseRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseRoundBracket) {
+		return errors.New(errString + p.expected(types.CloseRoundBracket))
 	}
-	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.ThenKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ThenKeyword) {
+		return errors.New(errString + p.expected(types.ThenKeyword))
 	}
-	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
+	p.GetNextToken()
 	// Parse Statements here
-	for {
-		if CheckTokenType(types.EndKeyword) || CheckTokenType(types.ElseKeyword) {
-			break
-		}
-		err = ParseStatement(&ifStatementNode, localSymbolTable)
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		GetNextToken()
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	err = p.ParseStatementList(&ifStatementNode, types.EndKeyword, types.ElseKeyword)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
-	if CheckTokenType(types.ElseKeyword) {
-		GetNextToken()
+	if p.CheckTokenType(types.ElseKeyword) {
+		ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
 		// Parse Statements here
-		for {
-			if CheckTokenType(types.EndKeyword) || CheckTokenType(types.ElseKeyword) {
-				break
-			}
-			err = ParseStatement(&ifStatementNode, localSymbolTable)
-			if err != nil {
-				return errors.New(errString + err.Error())
-			}
-			GetNextToken()
-			if CheckTokenType(types.SemiColonSymbol) {
-				semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-				ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, semiColonNode)
-			} else {
-				return errors.New(errString)
-			}
-
-			GetNextToken()
+		err = p.ParseStatementList(&ifStatementNode, types.EndKeyword, types.ElseKeyword)
+		if err != nil {
+			return errors.New(errString + err.Error())
 		}
 	}
 
-	if !CheckTokenType(types.EndKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
-	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, endKeywordNode)
-	GetNextToken()
-	if !CheckTokenType(types.IfKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IfKeyword) {
+		return errors.New(errString + p.expected(types.IfKeyword))
 	}
-	ifKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	ifKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	ifStatementNode.ChildNodes = append(ifStatementNode.ChildNodes, ifKeywordNode)
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, ifStatementNode)
@@ -835,83 +967,69 @@ func ParseIfStatement(parentNode *types.ParseNode, localSymbolTable *map[string]
 	return nil
 }
 
-func ParseLoopStatement(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseLoopStatement(parentNode *types.ParseNode) error {
 	loopStatementNode := types.ParseNode{Production: types.LoopStatementProd}
 	errString := "\nError parsing loop statement"
 
-	if !CheckTokenType(types.ForKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.ForKeyword) {
+		return errors.New(errString + p.expected(types.ForKeyword))
 	}
-	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.OpenRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
-	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
 	// Parse assignment statement
-	GetNextToken()
-	if CheckIfIdentifierExists(localSymbolTable) {
-		err := ParseAssignmentStatement(&loopStatementNode, localSymbolTable)
+	p.GetNextToken()
+	if p.CheckTokenType(types.IdentifierToken) {
+		err := p.ParseAssignmentStatement(&loopStatementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
 	} else {
-		return errors.New(errString)
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
 
 	// Parse semi colon
-	GetNextToken()
-	if !CheckTokenType(types.SemiColonSymbol) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.SemiColonSymbol) {
+		return errors.New(errString + p.expected(types.SemiColonSymbol))
 	}
-	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
 	// Parse expression
-	GetNextToken()
-	err := ParseExpression(&loopStatementNode, localSymbolTable) //, types.STVarBool)
+	p.GetNextToken()
+	err := p.ParseExpression(&loopStatementNode) //, types.STVarBool)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	GetNextToken()
-	if !CheckTokenType(types.CloseRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseRoundBracket) {
+		return errors.New(errString + p.expected(types.CloseRoundBracket))
 	}
-	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
+	p.GetNextToken()
 	// Parse Statements here
-	for {
-		if CheckTokenType(types.EndKeyword) {
-			break
-		}
-		err := ParseStatement(&loopStatementNode, localSymbolTable)
-		if err != nil {
-			return errors.New(errString + err.Error())
-		}
-		GetNextToken()
-		if CheckTokenType(types.SemiColonSymbol) {
-			semiColonNode := types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken}
-			loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, semiColonNode)
-		} else {
-			return errors.New(errString)
-		}
-
-		GetNextToken()
+	err = p.ParseStatementList(&loopStatementNode, types.EndKeyword)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
-	if !CheckTokenType(types.EndKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
-	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	endKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, endKeywordNode)
-	GetNextToken()
-	if !CheckTokenType(types.ForKeyword) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.ForKeyword) {
+		return errors.New(errString + p.expected(types.ForKeyword))
 	}
-	forKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken}
+	forKeywordNode := types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken}
 	loopStatementNode.ChildNodes = append(loopStatementNode.ChildNodes, forKeywordNode)
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, loopStatementNode)
@@ -919,547 +1037,448 @@ func ParseLoopStatement(parentNode *types.ParseNode, localSymbolTable *map[strin
 	return nil
 }
 
-func ParseReturnStatement(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	returnStatementNode := types.ParseNode{Production: types.ReturnStatementProd}
-	errString := "\nError parsing return statement"
+func (p *Parser) ParseWhileStatement(parentNode *types.ParseNode) error {
+	whileStatementNode := types.ParseNode{Production: types.WhileStatementProd}
+	errString := "\nError parsing while statement"
 
-	if !CheckTokenType(types.ReturnKeyword) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.WhileKeyword) {
+		return errors.New(errString + p.expected(types.WhileKeyword))
 	}
-	returnStatementNode.ChildNodes = append(returnStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	whileStatementNode.ChildNodes = append(whileStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	// Parse expression
-	GetNextToken()
-	err := ParseExpression(&returnStatementNode, localSymbolTable) //, types.STVarBool)
-	if err != nil {
-		return errors.New(errString + err.Error())
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
+	whileStatementNode.ChildNodes = append(whileStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, returnStatementNode)
-
-	return nil
-}
-
-func ParseDestination(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	destinationNode := types.ParseNode{Production: types.DestinationProd}
-	errString := "\nError parsing destination"
-
-	destinationNode.ChildNodes = append(destinationNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
-
-	isArray, err := CheckIfIdentifierIsArray(localSymbolTable)
+	// Parse expression
+	p.GetNextToken()
+	err := p.ParseExpression(&whileStatementNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	if CheckLookAhead(types.AssignmentOperator) {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, destinationNode)
-		return nil
-	}
-
-	GetNextToken()
-	if CheckTokenType(types.OpenSquareBracket) && isArray {
-		destinationNode.ChildNodes = append(destinationNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else if !CheckTokenType(types.OpenSquareBracket) && isArray {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, destinationNode)
-		return nil
-	} else if !CheckTokenType(types.OpenSquareBracket) && !isArray {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, destinationNode)
-		return nil
-	} else if CheckTokenType(types.OpenSquareBracket) && !isArray {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseRoundBracket) {
+		return errors.New(errString + p.expected(types.CloseRoundBracket))
 	}
+	whileStatementNode.ChildNodes = append(whileStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken() // TODO: switch back to this code after ParseExpression is finished
-	err = ParseExpression(&destinationNode, localSymbolTable)
+	p.GetNextToken()
+	// Parse Statements here
+	err = p.ParseStatementList(&whileStatementNode, types.EndKeyword)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	GetNextToken()
-	if CheckTokenType(types.CloseSquareBracket) {
-		destinationNode.ChildNodes = append(destinationNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
+	whileStatementNode.ChildNodes = append(whileStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+	p.GetNextToken()
+	if !p.CheckTokenType(types.WhileKeyword) {
+		return errors.New(errString + p.expected(types.WhileKeyword))
+	}
+	whileStatementNode.ChildNodes = append(whileStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, destinationNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, whileStatementNode)
 
 	return nil
 }
 
-func CheckIfEpsilon_Expression() bool {
-	if CheckLookAhead(types.SemiColonSymbol) ||
-		CheckLookAhead(types.CloseSquareBracket) ||
-		CheckLookAhead(types.CloseRoundBracket) ||
-		CheckLookAhead(types.CommaSymbol) ||
-		CheckLookAhead(types.AdditionOperator) ||
-		CheckLookAhead(types.SubtractionOperator) ||
-		CheckLookAhead(types.MultiplicationOperator) ||
-		CheckLookAhead(types.DivisionOperator) ||
-		CheckLookAhead(types.GreaterThanOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.GreaterThanEqualOperator) ||
-		CheckLookAhead(types.LessThanEqualOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.EqualOperator) ||
-		CheckLookAhead(types.NotEqualOperator) {
-		return true
-	} else {
-		return false
-	}
-}
+func (p *Parser) ParseCaseStatement(parentNode *types.ParseNode) error {
+	caseStatementNode := types.ParseNode{Production: types.CaseStatementProd}
+	errString := "\nError parsing case statement"
 
-func CheckIfEpsilon_ArithOp() bool {
-	if CheckLookAhead(types.SemiColonSymbol) ||
-		CheckLookAhead(types.CloseSquareBracket) ||
-		CheckLookAhead(types.CloseRoundBracket) ||
-		CheckLookAhead(types.CommaSymbol) ||
-		CheckLookAhead(types.AndOperator) ||
-		CheckLookAhead(types.OrOperator) ||
-		CheckLookAhead(types.MultiplicationOperator) ||
-		CheckLookAhead(types.DivisionOperator) ||
-		CheckLookAhead(types.GreaterThanOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.GreaterThanEqualOperator) ||
-		CheckLookAhead(types.LessThanEqualOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.EqualOperator) ||
-		CheckLookAhead(types.NotEqualOperator) {
-		return true
-	} else {
-		return false
+	if !p.CheckTokenType(types.CaseKeyword) {
+		return errors.New(errString + p.expected(types.CaseKeyword))
 	}
-}
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-func CheckIfEpsilon_Relation() bool {
-	if CheckLookAhead(types.SemiColonSymbol) ||
-		CheckLookAhead(types.CloseSquareBracket) ||
-		CheckLookAhead(types.CloseRoundBracket) ||
-		CheckLookAhead(types.CommaSymbol) ||
-		CheckLookAhead(types.AndOperator) ||
-		CheckLookAhead(types.OrOperator) ||
-		CheckLookAhead(types.AdditionOperator) ||
-		CheckLookAhead(types.SubtractionOperator) ||
-		CheckLookAhead(types.MultiplicationOperator) ||
-		CheckLookAhead(types.DivisionOperator) {
-		return true
-	} else {
-		return false
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
-}
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-func CheckIfEpsilon_Term() bool {
-	if CheckLookAhead(types.SemiColonSymbol) ||
-		CheckLookAhead(types.CloseSquareBracket) ||
-		CheckLookAhead(types.CloseRoundBracket) ||
-		CheckLookAhead(types.CommaSymbol) ||
-		CheckLookAhead(types.AndOperator) ||
-		CheckLookAhead(types.OrOperator) ||
-		CheckLookAhead(types.AdditionOperator) ||
-		CheckLookAhead(types.SubtractionOperator) ||
-		CheckLookAhead(types.GreaterThanOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.GreaterThanEqualOperator) ||
-		CheckLookAhead(types.LessThanEqualOperator) ||
-		CheckLookAhead(types.LessThanOperator) ||
-		CheckLookAhead(types.EqualOperator) ||
-		CheckLookAhead(types.NotEqualOperator) {
-		return true
-	} else {
-		return false
+	p.GetNextToken()
+	err := p.ParseExpression(&caseStatementNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
-}
 
-func ParseExpression(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	expressionNode := types.ParseNode{Production: types.ExpressionProd}
-	errString := "\nError parsing expression"
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseRoundBracket) {
+		return errors.New(errString + p.expected(types.CloseRoundBracket))
+	}
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	if CheckTokenType(types.NotOperator) {
-		expressionNode.ChildNodes = append(expressionNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
+	p.GetNextToken()
+	if !p.CheckTokenType(types.IsKeyword) {
+		return errors.New(errString + p.expected(types.IsKeyword))
+	}
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-		GetNextToken()
-		err := ParseArithOp(&expressionNode, localSymbolTable) //, types.STVarBool)
+	// At least one alternative, and when others only as the last
+	p.GetNextToken()
+	if !p.CheckTokenType(types.WhenKeyword) {
+		return errors.New(errString + p.expected(types.WhenKeyword))
+	}
+	for p.CheckTokenType(types.WhenKeyword) {
+		others, err := p.ParseCaseAlternative(&caseStatementNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-
-	} else {
-		err := ParseArithOp(&expressionNode, localSymbolTable) //, types.STVarBool)
-		if err != nil {
-			return errors.New(errString + err.Error())
+		if others {
+			break
 		}
 	}
 
-	// if CheckLookAhead(types.SemiColonSymbol) ||
-	// 	CheckLookAhead(types.CloseSquareBracket) ||
-	// 	CheckLookAhead(types.CloseRoundBracket) ||
-	// 	CheckLookAhead(types.AndOperator) ||
-	// 	CheckLookAhead(types.OrOperator) ||
-	// 	CheckLookAhead(types.CommaSymbol) {
-	// 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionNode)
-	// 	return nil
-	// }
-	if CheckIfEpsilon_Expression() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionNode)
-		return nil
+	if !p.CheckTokenType(types.EndKeyword) {
+		return errors.New(errString + p.expected(types.EndKeyword))
 	}
-
-	GetNextToken()
-	err := ParseExpressionPrime(&expressionNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CaseKeyword) {
+		return errors.New(errString + p.expected(types.CaseKeyword))
 	}
+	caseStatementNode.ChildNodes = append(caseStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, caseStatementNode)
 
 	return nil
 }
 
-func ParseExpressionPrime(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	expressionPrimeNode := types.ParseNode{Production: types.ExpressionPrimeProd}
-	errString := "\nError parsing expression prime"
+// ParseCaseAlternative parses one when clause and reports whether it
+// was the when others clause.  It stops at the token after the
+// clause's statements.
+func (p *Parser) ParseCaseAlternative(parentNode *types.ParseNode) (bool, error) {
+	alternativeNode := types.ParseNode{Production: types.CaseAlternativeProd}
+	errString := "\nError parsing case alternative"
 
-	if CheckTokenType(types.AndOperator) ||
-		CheckTokenType(types.OrOperator) {
-		expressionPrimeNode.ChildNodes = append(expressionPrimeNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.WhenKeyword) {
+		return false, errors.New(errString + p.expected(types.WhenKeyword))
 	}
+	alternativeNode.ChildNodes = append(alternativeNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	// Parse ArithOp
-	err := ParseArithOp(&expressionPrimeNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
+	p.GetNextToken()
+	others := p.CheckTokenType(types.OthersKeyword)
+	if others {
+		alternativeNode.ChildNodes = append(alternativeNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
+	} else {
+		for {
+			err := p.ParseCaseLabel(&alternativeNode)
+			if err != nil {
+				return false, errors.New(errString + err.Error())
+			}
+			p.GetNextToken()
+			if !p.CheckTokenType(types.CommaSymbol) {
+				break
+			}
+			alternativeNode.ChildNodes = append(alternativeNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+			p.GetNextToken()
+		}
 	}
 
-	if CheckIfEpsilon_Expression() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionPrimeNode)
-		return nil
+	if !p.CheckTokenType(types.ThenKeyword) {
+		if others {
+			return false, errors.New(errString + p.expected(types.ThenKeyword))
+		}
+		return false, errors.New(errString + p.expected(types.CommaSymbol, types.ThenKeyword))
 	}
+	alternativeNode.ChildNodes = append(alternativeNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	// Parse ExpressionPrime
-	err = ParseArithOpPrime(&expressionPrimeNode, localSymbolTable)
+	p.GetNextToken()
+	err := p.ParseStatementList(&alternativeNode, types.WhenKeyword, types.EndKeyword)
 	if err != nil {
-		return errors.New(errString + err.Error())
+		return false, errors.New(errString + err.Error())
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionPrimeNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, alternativeNode)
 
-	return nil
+	return others, nil
 }
 
-func ParseArithOp(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	arithOpNode := types.ParseNode{Production: types.ArithOpProd}
-	errString := "\nError parsing arithmetic operation"
+// ParseCaseLabel parses an integer literal, which may be negated, or
+// the name of an enum value.
+func (p *Parser) ParseCaseLabel(parentNode *types.ParseNode) error {
+	labelNode := types.ParseNode{Production: types.CaseLabelProd}
+	errString := "\nError parsing case label"
 
-	err := ParseRelation(&arithOpNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
+	if p.CheckTokenType(types.IdentifierToken) {
+		labelNode.ChildNodes = append(labelNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
+	} else {
+		if p.CheckTokenType(types.SubtractionOperator) {
+			labelNode.ChildNodes = append(labelNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+			p.GetNextToken()
+		} else if !p.CheckTokenType(types.IntegerToken) {
+			return errors.New(errString + p.expected(types.IntegerToken, types.IdentifierToken))
+		}
+		err := p.ParseInteger(&labelNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
 	}
 
-	if CheckIfEpsilon_ArithOp() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, arithOpNode)
-		return nil
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, labelNode)
+
+	return nil
+}
+
+func (p *Parser) ParseReturnStatement(parentNode *types.ParseNode) error {
+	returnStatementNode := types.ParseNode{Production: types.ReturnStatementProd}
+	errString := "\nError parsing return statement"
+
+	if !p.CheckTokenType(types.ReturnKeyword) {
+		return errors.New(errString + p.expected(types.ReturnKeyword))
 	}
+	returnStatementNode.ChildNodes = append(returnStatementNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	err = ParseArithOpPrime(&arithOpNode, localSymbolTable)
+	// Parse expression
+	p.GetNextToken()
+	err := p.ParseExpression(&returnStatementNode) //, types.STVarBool)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, arithOpNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, returnStatementNode)
 
 	return nil
 }
 
-func ParseArithOpPrime(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	arithOpPrimeNode := types.ParseNode{Production: types.ArithOpPrimeProd}
-	errString := "\nError parsing arithmetic operation prime"
-
-	if CheckTokenType(types.AdditionOperator) ||
-		CheckTokenType(types.SubtractionOperator) {
-		arithOpPrimeNode.ChildNodes = append(arithOpPrimeNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
-	}
+func (p *Parser) ParseDestination(parentNode *types.ParseNode) error {
+	destinationNode := types.ParseNode{Production: types.DestinationProd}
+	errString := "\nError parsing destination"
 
-	GetNextToken()
-	// Parse Relation
-	err := ParseRelation(&arithOpPrimeNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
+	destinationNode.ChildNodes = append(destinationNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-	if CheckIfEpsilon_ArithOp() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, arithOpPrimeNode)
-		return nil
+	if p.CheckLookAhead(types.OpenSquareBracket) {
+		err := p.ParseIndex(&destinationNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
 	}
 
-	GetNextToken()
-	// Parse ArithOpPrime
-	err = ParseArithOpPrime(&arithOpPrimeNode, localSymbolTable)
+	err := p.ParseFields(&destinationNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, arithOpPrimeNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, destinationNode)
 
 	return nil
 }
 
-func ParseRelation(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	relationNode := types.ParseNode{Production: types.RelationProd}
-	errString := "\nError parsing relation"
-
-	err := ParseTerm(&relationNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
+// ParseIndex parses "[ expression ]" after a name or field, starting
+// from the token before the bracket.
+func (p *Parser) ParseIndex(parentNode *types.ParseNode) error {
+	errString := "\nError parsing index"
 
-	if CheckIfEpsilon_Relation() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, relationNode)
-		return nil
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenSquareBracket) {
+		return errors.New(errString + p.expected(types.OpenSquareBracket))
 	}
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	err = ParseRelationPrime(&relationNode, localSymbolTable)
+	p.GetNextToken()
+	err := p.ParseExpression(parentNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, relationNode)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseSquareBracket) {
+		return errors.New(errString + p.expected(types.CloseSquareBracket))
+	}
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
 	return nil
 }
 
-func ParseRelationPrime(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	relationPrimeNode := types.ParseNode{Production: types.RelationPrimeProd}
-	errString := "\nError parsing relation prime"
-
-	if CheckTokenType(types.LessThanOperator) ||
-		CheckTokenType(types.GreaterThanEqualOperator) ||
-		CheckTokenType(types.LessThanEqualOperator) ||
-		CheckTokenType(types.GreaterThanOperator) ||
-		CheckTokenType(types.EqualOperator) ||
-		CheckTokenType(types.NotEqualOperator) {
-		relationPrimeNode.ChildNodes = append(relationPrimeNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
-	}
+// ParseFields parses any ". identifier [ index ]" field selections
+// after a name.  Whether the fields exist is left to semantic
+// analysis, which knows the type of what they are selected from.
+func (p *Parser) ParseFields(parentNode *types.ParseNode) error {
+	errString := "\nError parsing field"
 
-	GetNextToken()
-	// Parse Term
-	err := ParseTerm(&relationPrimeNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
+	for p.CheckLookAhead(types.PeriodSymbol) {
+		fieldNode := types.ParseNode{Production: types.FieldProd}
 
-	if CheckIfEpsilon_Relation() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, relationPrimeNode)
-		return nil
-	}
+		p.GetNextToken()
+		fieldNode.ChildNodes = append(fieldNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	// Parse RelationPrime
-	err = ParseRelationPrime(&relationPrimeNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
-	}
+		p.GetNextToken()
+		if !p.CheckTokenType(types.IdentifierToken) {
+			return errors.New(errString + p.expected(types.IdentifierToken))
+		}
+		fieldNode.ChildNodes = append(fieldNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
+
+		if p.CheckLookAhead(types.OpenSquareBracket) {
+			err := p.ParseIndex(&fieldNode)
+			if err != nil {
+				return errors.New(errString + err.Error())
+			}
+		}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, relationPrimeNode)
+		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, fieldNode)
+	}
 
 	return nil
 }
 
-func ParseTerm(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	termNode := types.ParseNode{Production: types.TermProd}
-	errString := "\nError parsing term"
+// binaryPrecedence is the precedence table for the binary operators.
+// A higher number binds more tightly and operators of equal
+// precedence associate to the left.  not, which may only start an
+// expression, binds more loosely than all of them and unary minus,
+// which is part of a factor, more tightly.
+var binaryPrecedence = map[types.TokenType]int{
+	types.AndOperator:              1,
+	types.OrOperator:               1,
+	types.LessThanOperator:         2,
+	types.LessThanEqualOperator:    2,
+	types.GreaterThanOperator:      2,
+	types.GreaterThanEqualOperator: 2,
+	types.EqualOperator:            2,
+	types.NotEqualOperator:         2,
+	types.AdditionOperator:         3,
+	types.SubtractionOperator:      3,
+	types.MultiplicationOperator:   4,
+	types.DivisionOperator:         4,
+}
 
-	err := ParseFactor(&termNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
+// lookAheadPrecedence returns the precedence of the next token if it
+// is a binary operator.
+func (p *Parser) lookAheadPrecedence() (int, bool) {
+	if p.tokenIndex >= len(p.tokenList) {
+		return 0, false
 	}
+	precedence, isOperator := binaryPrecedence[p.tokenList[p.tokenIndex].TokenType]
+	return precedence, isOperator
+}
 
-	if CheckIfEpsilon_Term() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, termNode)
-		return nil
-	}
+func (p *Parser) ParseExpression(parentNode *types.ParseNode) error {
+	expressionNode := types.ParseNode{Production: types.ExpressionProd}
+	errString := "\nError parsing expression"
 
-	GetNextToken()
-	err = ParseTermPrime(&termNode, localSymbolTable)
+	err := p.ParseBinaryOperation(&expressionNode, 1)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, termNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, expressionNode)
 
 	return nil
 }
 
-func ParseTermPrime(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
-	termPrimeNode := types.ParseNode{Production: types.TermPrimeProd}
-	errString := "\nError parsing term prime"
+// ParseBinaryOperation parses factors joined by operators of at least
+// minPrecedence by precedence climbing.  The right operand of each
+// operator is the run of more tightly binding operators after it, and
+// the result becomes the left operand of the next operator, so that
+// a - b - c is parsed as (a - b) - c.  A lone factor is not wrapped.
+func (p *Parser) ParseBinaryOperation(parentNode *types.ParseNode, minPrecedence int) error {
+	errString := "\nError parsing binary operation"
 
-	if CheckTokenType(types.MultiplicationOperator) ||
-		CheckTokenType(types.DivisionOperator) {
-		termPrimeNode.ChildNodes = append(termPrimeNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-	} else {
-		return errors.New(errString)
-	}
-
-	GetNextToken()
-	// Parse Factor
-	err := ParseFactor(&termPrimeNode, localSymbolTable)
+	operandNode := types.ParseNode{}
+	err := p.ParseFactor(&operandNode)
 	if err != nil {
 		return errors.New(errString + err.Error())
 	}
+	leftNode := operandNode.ChildNodes[0]
 
-	if CheckIfEpsilon_Term() {
-		(*parentNode).ChildNodes = append((*parentNode).ChildNodes, termPrimeNode)
-		return nil
-	}
+	for {
+		precedence, isOperator := p.lookAheadPrecedence()
+		if !isOperator || precedence < minPrecedence {
+			break
+		}
 
-	GetNextToken()
-	// Parse TermPrime
-	err = ParseTermPrime(&termPrimeNode, localSymbolTable)
-	if err != nil {
-		return errors.New(errString + err.Error())
+		p.GetNextToken()
+		binaryOperationNode := types.ParseNode{Production: types.BinaryOperationProd}
+		binaryOperationNode.ChildNodes = append(binaryOperationNode.ChildNodes, leftNode)
+		binaryOperationNode.ChildNodes = append(binaryOperationNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+
+		p.GetNextToken()
+		err = p.ParseBinaryOperation(&binaryOperationNode, precedence+1)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+		leftNode = binaryOperationNode
 	}
 
-	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, termPrimeNode)
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, leftNode)
 
 	return nil
 }
 
-func ParseFactor(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+// ParseFactor parses an operand of a binary operation.  Unary minus
+// binds more tightly than any binary operator and applies to the
+// factor after it.  not binds more loosely than any, so its operand is
+// the rest of the expression: not a < b is not (a < b).
+func (p *Parser) ParseFactor(parentNode *types.ParseNode) error {
 	factorNode := types.ParseNode{Production: types.FactorProd}
 	errString := "\nError parsing factor"
 
-	if CheckTokenType(types.OpenRoundBracket) {
-		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	if p.CheckTokenType(types.OpenRoundBracket) {
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-		GetNextToken()
-		err := ParseExpression(&factorNode, localSymbolTable)
+		p.GetNextToken()
+		err := p.ParseExpression(&factorNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
 
-		GetNextToken()
-		if !CheckTokenType(types.CloseRoundBracket) {
-			return errors.New(errString + err.Error())
+		p.GetNextToken()
+		if !p.CheckTokenType(types.CloseRoundBracket) {
+			return errors.New(errString + p.expected(types.CloseRoundBracket))
 		}
-		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	} else if CheckTokenType(types.IdentifierToken) {
-		entryLocal, isLocal := (*localSymbolTable)[currentToken.StringValue]
-		if isLocal {
-			if entryLocal.EntryType == types.STProcedure {
-				err := ParseProcedureCall(&factorNode, localSymbolTable)
-				if err != nil {
-					return errors.New(errString + err.Error())
-				}
-			} else {
-				err := ParseName(&factorNode, localSymbolTable)
-				if err != nil {
-					return errors.New(errString + err.Error())
-				}
-			}
-		} else {
-			entryGlobal, isGlobal := globalSymbolTable[currentToken.StringValue]
-			if isGlobal {
-				if entryGlobal.EntryType == types.STProcedure {
-					err := ParseProcedureCall(&factorNode, localSymbolTable)
-					if err != nil {
-						return errors.New(errString + err.Error())
-					}
-				} else {
-					err := ParseName(&factorNode, &globalSymbolTable)
-					if err != nil {
-						return errors.New(errString + err.Error())
-					}
-				}
-			} else {
-				entryBuiltin, isBuiltin := builtinSymbolTable[currentToken.StringValue]
-				if isBuiltin {
-					if entryBuiltin.EntryType == types.STProcedure {
-						err := ParseProcedureCall(&factorNode, localSymbolTable)
-						if err != nil {
-							return errors.New(errString + err.Error())
-						}
-					} else {
-						return errors.New(errString)
-					}
-				} else {
-					return errors.New(errString)
-				}
-			}
+	} else if p.CheckTokenType(types.IdentifierToken) && p.CheckLookAhead(types.OpenRoundBracket) {
+		err := p.ParseProcedureCall(&factorNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.IntegerToken) {
-		err := ParseInteger(&factorNode)
+	} else if p.CheckTokenType(types.IdentifierToken) {
+		err := p.ParseName(&factorNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.FloatToken) {
-		err := ParseFloat(&factorNode)
+	} else if p.CheckTokenType(types.IntegerToken) {
+		err := p.ParseInteger(&factorNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.StringToken) {
-		err := ParseString(&factorNode)
+	} else if p.CheckTokenType(types.FloatToken) {
+		err := p.ParseFloat(&factorNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-	} else if CheckTokenType(types.TrueKeyword) {
-		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
-	} else if CheckTokenType(types.FalseKeyword) {
-		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: currentToken})
-	} else if CheckTokenType(types.SubtractionOperator) {
-		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-		GetNextToken()
-		if CheckTokenType(types.IdentifierToken) {
-			entryLocal, isLocal := (*localSymbolTable)[currentToken.StringValue]
-			if isLocal {
-				if entryLocal.EntryType == types.STProcedure {
-					return errors.New(errString)
-				} else {
-					err := ParseName(&factorNode, localSymbolTable)
-					if err != nil {
-						return errors.New(errString + err.Error())
-					}
-				}
-			} else {
-				entryGlobal, isGlobal := globalSymbolTable[currentToken.StringValue]
-				if isGlobal {
-					if entryGlobal.EntryType == types.STProcedure {
-						return errors.New(errString)
-					} else {
-						err := ParseName(&factorNode, localSymbolTable)
-						if err != nil {
-							return errors.New(errString + err.Error())
-						}
-					}
-				} else {
-					return errors.New(errString)
-				}
-			}
-		} else if CheckTokenType(types.IntegerToken) {
-			err := ParseInteger(&factorNode)
-			if err != nil {
-				return errors.New(errString + err.Error())
-			}
-		} else if CheckTokenType(types.FloatToken) {
-			err := ParseFloat(&factorNode)
-			if err != nil {
-				return errors.New(errString + err.Error())
-			}
-		} else {
-			return errors.New(errString)
+	} else if p.CheckTokenType(types.StringToken) {
+		err := p.ParseString(&factorNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.TrueKeyword) {
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+	} else if p.CheckTokenType(types.FalseKeyword) {
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+	} else if p.CheckTokenType(types.SubtractionOperator) {
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
+		err := p.ParseFactor(&factorNode)
+		if err != nil {
+			return errors.New(errString + err.Error())
+		}
+	} else if p.CheckTokenType(types.NotOperator) {
+		factorNode.ChildNodes = append(factorNode.ChildNodes, types.ParseNode{Production: types.KeywordTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
+		err := p.ParseBinaryOperation(&factorNode, 1)
+		if err != nil {
+			return errors.New(errString + err.Error())
 		}
 	} else {
-		return errors.New(errString)
+		return errors.New(errString + p.expectedWhat("an expression"))
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, factorNode)
@@ -1467,37 +1486,34 @@ func ParseFactor(parentNode *types.ParseNode, localSymbolTable *map[string]types
 	return nil
 }
 
-func ParseProcedureCall(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseProcedureCall(parentNode *types.ParseNode) error {
 	procedureCallNode := types.ParseNode{Production: types.ProcedureCallProd}
 	errString := "\nError parsing procedure call"
 
-	if !CheckIfIdentifierExists(localSymbolTable) {
-		return errors.New(errString)
-	}
-	if !CheckTokenType(types.IdentifierToken) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
-	procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
+	procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.OpenRoundBracket) {
-		return errors.New(errString)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.OpenRoundBracket) {
+		return errors.New(errString + p.expected(types.OpenRoundBracket))
 	}
-	procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 
-	GetNextToken()
-	if !CheckTokenType(types.CloseRoundBracket) {
-		err := ParseArgumentList(&procedureCallNode, localSymbolTable)
+	p.GetNextToken()
+	if !p.CheckTokenType(types.CloseRoundBracket) {
+		err := p.ParseArgumentList(&procedureCallNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-		// GetNextToken()
-		if !CheckTokenType(types.CloseRoundBracket) {
-			return errors.New(errString)
+		// p.GetNextToken()
+		if !p.CheckTokenType(types.CloseRoundBracket) {
+			return errors.New(errString + p.expected(types.CloseRoundBracket))
 		}
-		procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+		procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 	} else {
-		procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+		procedureCallNode.ChildNodes = append(procedureCallNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, procedureCallNode)
@@ -1505,66 +1521,50 @@ func ParseProcedureCall(parentNode *types.ParseNode, localSymbolTable *map[strin
 	return nil
 }
 
-func ParseArgumentList(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseArgumentList(parentNode *types.ParseNode) error {
 	argumentListNode := types.ParseNode{Production: types.ArgumentListProd}
 	errString := "\nError parsing argument list"
 
 	for {
-		err := ParseExpression(&argumentListNode, localSymbolTable)
+		err := p.ParseExpression(&argumentListNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
-		GetNextToken()
-		if CheckTokenType(types.CommaSymbol) {
-			argumentListNode.ChildNodes = append(argumentListNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
-			GetNextToken()
-		} else if CheckTokenType(types.CloseRoundBracket) {
+		p.GetNextToken()
+		if p.CheckTokenType(types.CommaSymbol) {
+			argumentListNode.ChildNodes = append(argumentListNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: p.currentToken})
+			p.GetNextToken()
+		} else if p.CheckTokenType(types.CloseRoundBracket) {
 			(*parentNode).ChildNodes = append((*parentNode).ChildNodes, argumentListNode)
 			return nil
 		} else {
-			return errors.New(errString)
+			return errors.New(errString + p.expected(types.CommaSymbol, types.CloseRoundBracket))
 		}
 	}
 }
 
-func ParseName(parentNode *types.ParseNode, localSymbolTable *map[string]types.STEntry) error {
+func (p *Parser) ParseName(parentNode *types.ParseNode) error {
 	nameNode := types.ParseNode{Production: types.NameProd}
 	errString := "\nError parsing name"
 
-	if !CheckIfIdentifierExists(localSymbolTable) {
-		return errors.New(errString)
+	if !p.CheckTokenType(types.IdentifierToken) {
+		return errors.New(errString + p.expected(types.IdentifierToken))
 	}
-	if !CheckTokenType(types.IdentifierToken) {
-		return errors.New(errString)
-	}
-	nameNode.ChildNodes = append(nameNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: currentToken})
-
-	if CheckLookAhead(types.OpenSquareBracket) {
-		GetNextToken()
-		nameNode.ChildNodes = append(nameNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	nameNode.ChildNodes = append(nameNode.ChildNodes, types.ParseNode{Production: types.IdentifierProd, TerminalToken: p.currentToken})
 
-		GetNextToken()
-		err := ParseExpression(&nameNode, localSymbolTable)
+	if p.CheckLookAhead(types.OpenSquareBracket) {
+		err := p.ParseIndex(&nameNode)
 		if err != nil {
 			return errors.New(errString + err.Error())
 		}
+	}
 
-		GetNextToken()
-		if !CheckTokenType(types.CloseSquareBracket) {
-			return errors.New(errString)
-		}
-		nameNode.ChildNodes = append(nameNode.ChildNodes, types.ParseNode{Production: types.SymbolTerminal, TerminalToken: currentToken})
+	err := p.ParseFields(&nameNode)
+	if err != nil {
+		return errors.New(errString + err.Error())
 	}
 
 	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, nameNode)
 
 	return nil
 }
-
-func GetGlobalSymbolTable() map[string]types.STEntry {
-	return globalSymbolTable
-}
-
-func GetBuiltinSymbolTable() map[string]types.STEntry {
-	return builtinSymbolTable
-}
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"compiler/src/scanner"
+	"compiler/src/types"
+	"strings"
+	"testing"
+)
+
+// operatorLevels lists the binary operators from the loosest binding
+// level to the tightest.
+var operatorLevels = [][]string{
+	{"&", "|"},
+	{"<", "<=", ">", ">=", "==", "!="},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+// parseExpression parses source as a single expression.
+func parseExpression(t *testing.T, source string) types.ParseNode {
+	t.Helper()
+	tokenList, err := scanner.NewLexerFromString(source, "test").ScanAll()
+	if err != nil {
+		t.Fatalf("%s: %v", source, err)
+	}
+	p := NewParser(tokenList)
+	p.GetNextToken()
+	root := types.ParseNode{}
+	err = p.ParseExpression(&root)
+	if err != nil {
+		t.Fatalf("%s: %v", source, err)
+	}
+	p.GetNextToken()
+	if !p.CheckTokenType(types.EOFToken) {
+		t.Fatalf("%s: expression ended early at %q", source, p.currentToken.Lexeme)
+	}
+	return root.ChildNodes[0]
+}
+
+// shape writes out an expression tree with every binary and unary
+// operation in round brackets.  Brackets in the source become square
+// ones, so they can be told apart.
+func shape(node types.ParseNode) string {
+	switch node.Production {
+	case types.ExpressionProd:
+		return shape(node.ChildNodes[0])
+	case types.BinaryOperationProd:
+		return "(" + shape(node.ChildNodes[0]) + " " + node.ChildNodes[1].TerminalToken.Lexeme + " " + shape(node.ChildNodes[2]) + ")"
+	case types.FactorProd:
+		first := node.ChildNodes[0]
+		if len(first.ChildNodes) == 0 {
+			switch first.TerminalToken.TokenType {
+			case types.OpenRoundBracket:
+				return "[" + shape(node.ChildNodes[1]) + "]"
+			case types.SubtractionOperator:
+				return "(-" + shape(node.ChildNodes[1]) + ")"
+			case types.NotOperator:
+				return "(not " + shape(node.ChildNodes[1]) + ")"
+			}
+		}
+		return shape(first)
+	}
+	var words []string
+	if len(node.ChildNodes) == 0 {
+		words = append(words, node.TerminalToken.Lexeme)
+	}
+	for _, child := range node.ChildNodes {
+		words = append(words, shape(child))
+	}
+	return strings.Join(words, "")
+}
+
+// TestOperatorPairs parses a op1 b op2 c for every pair of binary
+// operators.  The tighter operator takes b, and of two operators at
+// the same level the left one does.
+func TestOperatorPairs(t *testing.T) {
+	for level1, operators1 := range operatorLevels {
+		for level2, operators2 := range operatorLevels {
+			for _, op1 := range operators1 {
+				for _, op2 := range operators2 {
+					source := "a " + op1 + " b " + op2 + " c"
+					want := "((a " + op1 + " b) " + op2 + " c)"
+					if level2 > level1 {
+						want = "(a " + op1 + " (b " + op2 + " c))"
+					}
+					if got := shape(parseExpression(t, source)); got != want {
+						t.Errorf("%s: got %s, want %s", source, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestUnaryOperators checks not and unary minus against each level of
+// binary operator.  not takes the rest of the expression after it and
+// unary minus only the factor after it.
+func TestUnaryOperators(t *testing.T) {
+	for _, operators := range operatorLevels {
+		for _, op := range operators {
+			tests := []struct {
+				source string
+				want   string
+			}{
+				{"not a " + op + " b", "(not (a " + op + " b))"},
+				{"a " + op + " not b", "(a " + op + " (not b))"},
+				{"a " + op + " not b " + op + " c", "(a " + op + " (not (b " + op + " c)))"},
+				{"-a " + op + " b", "((-a) " + op + " b)"},
+				{"a " + op + " -b", "(a " + op + " (-b))"},
+				{"-(a " + op + " b)", "(-[(a " + op + " b)])"},
+				{"not (a " + op + " b) " + op + " c", "(not ([(a " + op + " b)] " + op + " c))"},
+			}
+			for _, test := range tests {
+				if got := shape(parseExpression(t, test.source)); got != test.want {
+					t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+				}
+			}
+		}
+	}
+}
+
+func TestExpressionShapes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"a", "a"},
+		{"a - b - c - d", "(((a - b) - c) - d)"},
+		{"a / b / c", "((a / b) / c)"},
+		{"a / b * c", "((a / b) * c)"},
+		{"a - (b - c)", "(a - [(b - c)])"},
+		{"(a + b) * c", "([(a + b)] * c)"},
+		{"a + b * c - d", "((a + (b * c)) - d)"},
+		{"a < b + c & d", "((a < (b + c)) & d)"},
+		{"a | b & c", "((a | b) & c)"},
+		{"- - a * b", "((-(-a)) * b)"},
+		{"not not a", "(not (not a))"},
+		{"f(a + b) * x[i - 1]", "(f((a + b)) * x[(i - 1)])"},
+	}
+	for _, test := range tests {
+		if got := shape(parseExpression(t, test.source)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.source, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Syntax error reporting and recovery.
+// A token that does not fit the grammar is reported as
+// "expected X, found Y" at the token's location.
+// The parser uses panic mode: when a declaration or statement fails
+// to parse, the error is recorded, the parser rewinds to the first
+// token of the construct and skips forward to a synchronizing token.
+// The skipped tokens are kept in the tree under an error node so that
+// parsing can carry on and report every syntax error in one run.
+
+package parser
+
+import (
+	"compiler/src/types"
+	"errors"
+	"strings"
+)
+
+// Keywords that open a block closed by "end <keyword>".  Nesting is
+// tracked through them, and through braces, so that the body of a
+// broken block is skipped along with its header.
+var statementOpeners = []types.TokenType{types.IfKeyword, types.ForKeyword, types.WhileKeyword, types.CaseKeyword}
+var declarationOpeners = []types.TokenType{types.ProcedureKeyword}
+
+// Tokens that start the next statement or declaration when no
+// semicolon was found.
+var statementStops = []types.TokenType{types.ElseKeyword, types.WhenKeyword}
+var declarationStops = []types.TokenType{types.BeginKeyword, types.GlobalKeyword, types.VariableKeyword, types.ProcedureKeyword, types.TypeKeyword, types.ConstantKeyword}
+
+// recordError adds err, raised at the current token, to the
+// diagnostics.  err is a chain of "Error parsing" lines ending in the
+// innermost error; the diagnostic reads the innermost error followed
+// by the production it was found in.
+func (p *Parser) recordError(err error) {
+	lines := strings.Split(strings.TrimPrefix(err.Error(), "\n"), "\n")
+	message := strings.TrimPrefix(lines[len(lines)-1], "Error: ")
+	for i := len(lines) - 2; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "Error parsing ") {
+			message += " (in " + strings.TrimPrefix(lines[i], "Error parsing ") + ")"
+			break
+		}
+	}
+	p.diagnostics.Add(types.NewDiagnostic(p.currentToken.Span, message))
+}
+
+// expected returns the innermost line of a syntax error for a current
+// token that is not one of the expected token types.
+func (p *Parser) expected(expected ...types.TokenType) string {
+	names := make([]string, len(expected))
+	for i, tokenType := range expected {
+		names[i] = describeTokenType(tokenType)
+	}
+	what := names[0]
+	if len(names) > 1 {
+		what = strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+	return p.expectedWhat(what)
+}
+
+// expectedWhat is expected for a description of what was wanted
+// that is not a set of token types.
+func (p *Parser) expectedWhat(what string) string {
+	return "\nError: expected " + what + ", found " + describeToken(p.currentToken)
+}
+
+// describeTokenType names a token type for a diagnostic.  Keywords and
+// symbols are quoted as written.
+func describeTokenType(tokenType types.TokenType) string {
+	switch tokenType {
+	case types.IdentifierToken:
+		return "identifier"
+	case types.IntegerToken:
+		return "integer literal"
+	case types.FloatToken:
+		return "float literal"
+	case types.StringToken:
+		return "string literal"
+	case types.EOFToken:
+		return "end of file"
+	}
+	return "\"" + string(tokenType) + "\""
+}
+
+// describeToken names a token for a diagnostic, with its spelling
+// where the type alone does not give it.
+func describeToken(token types.Token) string {
+	switch token.TokenType {
+	case types.IdentifierToken, types.IntegerToken, types.FloatToken, types.StringToken:
+		return describeTokenType(token.TokenType) + " " + token.Lexeme
+	}
+	return describeTokenType(token.TokenType)
+}
+
+func (p *Parser) checkAny(checkTypes []types.TokenType) bool {
+	for _, checkType := range checkTypes {
+		if p.CheckTokenType(checkType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) checkLookAheadAny(checkTypes []types.TokenType) bool {
+	for _, checkType := range checkTypes {
+		if p.CheckLookAhead(checkType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) isStatementStart() bool {
+	return p.checkAny([]types.TokenType{types.IdentifierToken, types.IfKeyword, types.ForKeyword, types.WhileKeyword, types.CaseKeyword, types.ReturnKeyword, types.ExitKeyword, types.ContinueKeyword})
+}
+
+// skipStatement skips the statement beginning at startIndex.
+func (p *Parser) skipStatement(parentNode *types.ParseNode, startIndex int) error {
+	return p.synchronize(parentNode, startIndex, statementOpeners, statementStops)
+}
+
+// skipDeclaration skips the declaration beginning at startIndex.
+func (p *Parser) skipDeclaration(parentNode *types.ParseNode, startIndex int) error {
+	return p.synchronize(parentNode, startIndex, declarationOpeners, declarationStops)
+}
+
+// synchronize rewinds to startIndex and moves the tokens from there
+// into an error node until it reaches a semicolon at the starting
+// nesting level, which becomes the current token.  Skipping also
+// stops, without consuming the token, at an "end" that closes the
+// enclosing block or at one of the stop tokens.  The first token is
+// always skipped so that the caller makes progress.
+func (p *Parser) synchronize(parentNode *types.ParseNode, startIndex int, openers []types.TokenType, stops []types.TokenType) error {
+	errorNode := types.ParseNode{Production: types.ErrorProd}
+	errString := "\nError: unexpected end of file"
+
+	p.tokenIndex = startIndex
+	p.GetNextToken()
+	// A leading global belongs to the declaration that follows it
+	leading := 1
+	if p.CheckTokenType(types.GlobalKeyword) {
+		leading = 2
+	}
+
+	depth := 0
+	for skipped := 0; ; skipped++ {
+		if p.CheckTokenType(types.EOFToken) {
+			(*parentNode).ChildNodes = append((*parentNode).ChildNodes, errorNode)
+			return errors.New(errString)
+		}
+		if depth == 0 && p.CheckTokenType(types.SemiColonSymbol) {
+			break
+		}
+		if depth == 0 && skipped >= leading && p.checkAny(stops) {
+			break
+		}
+		if p.CheckTokenType(types.EndKeyword) {
+			if depth == 0 && skipped > 0 {
+				break
+			}
+			if p.checkLookAheadAny(openers) {
+				if depth > 0 {
+					depth--
+				}
+				errorNode.ChildNodes = append(errorNode.ChildNodes, types.ParseNode{Production: types.SkippedTerminal, TerminalToken: p.currentToken})
+				p.GetNextToken()
+			}
+		} else if p.checkAny(openers) || p.CheckTokenType(types.OpenCurlyBracket) {
+			depth++
+		} else if p.CheckTokenType(types.CloseCurlyBracket) && depth > 0 {
+			depth--
+		}
+		errorNode.ChildNodes = append(errorNode.ChildNodes, types.ParseNode{Production: types.SkippedTerminal, TerminalToken: p.currentToken})
+		p.GetNextToken()
+	}
+
+	(*parentNode).ChildNodes = append((*parentNode).ChildNodes, errorNode)
+
+	return nil
+}
@@ -0,0 +1,482 @@
+// The entry point function is Resolve
+// Resolve creates a Resolver which binds every name in the abstract
+// syntax tree to the symbol table entry of its declaration and fills
+// in the types the parser left as names.  The names declared in the
+// program, and the globals declared in its procedures, are all known
+// before any of them is used, so procedures and variables at global
+// scope may be used before they are declared and procedures may call
+// each other.  Inside a procedure names are
+// declared in order.  Type names must always be declared before use,
+// and so must variables used in an initializer, since initializers
+// run in the order they are declared.
+
+package resolver
+
+import (
+	"compiler/src/ast"
+	"compiler/src/types"
+	"strings"
+)
+
+// Options selects optional Resolver behaviour.
+type Options struct {
+	// CaseSensitive selects the strict dialect, in which identifiers
+	// that differ only in case are distinct.  It must match the
+	// setting the tokens were scanned with.
+	CaseSensitive bool
+}
+
+// Resolver holds the state for resolving one program.
+type Resolver struct {
+	options     Options
+	builtins    map[string]*types.STEntry
+	globals     *scope
+	diagnostics types.DiagnosticList
+	// declaring is the type whose definition is being resolved, which
+	// cannot be used inside itself.
+	declaring *types.STEntry
+	// pending holds the global variables whose declarations have not
+	// been reached yet, which initializers run at program start cannot
+	// use.
+	pending map[*types.STEntry]bool
+	// initializing is the variable whose initializer is being
+	// resolved, if any, and atStart is set if that initializer runs
+	// at program start.
+	initializing *ast.VarDecl
+	atStart      bool
+	// hoisting is set while the globals declared in procedures are
+	// declared ahead of the procedures, and hoisted holds their names.
+	hoisting bool
+	hoisted  map[*ast.Ident]bool
+	// nested holds the procedures declared in procedures.  Their
+	// static links cannot be set up at program start, so initializers
+	// that run then cannot call them.
+	nested map[*types.STEntry]bool
+}
+
+// scope is the names declared in the program or a procedure, and the
+// scope of the procedure around it.
+type scope struct {
+	symbols map[string]*types.STEntry
+	parent  *scope
+}
+
+// NewResolver returns a Resolver with the builtin procedures
+// already declared.
+func NewResolver(options Options) *Resolver {
+	r := &Resolver{
+		options:  options,
+		builtins: map[string]*types.STEntry{},
+		globals:  &scope{symbols: map[string]*types.STEntry{}},
+		pending:  map[*types.STEntry]bool{},
+		hoisted:  map[*ast.Ident]bool{},
+		nested:   map[*types.STEntry]bool{},
+	}
+
+	r.AddBuiltin("getBool", types.STVarBool)
+	r.AddBuiltin("getInteger", types.STVarInteger)
+	r.AddBuiltin("getFloat", types.STVarFloat)
+	r.AddBuiltin("getString", types.STVarString)
+	r.AddBuiltin("putBool", types.STVarBool, types.STVarBool).IgnorableResult = true
+	r.AddBuiltin("putInteger", types.STVarBool, types.STVarInteger).IgnorableResult = true
+	r.AddBuiltin("putFloat", types.STVarBool, types.STVarFloat).IgnorableResult = true
+	r.AddBuiltin("putString", types.STVarBool, types.STVarString).IgnorableResult = true
+	r.AddBuiltin("sqrt", types.STVarFloat, types.STVarInteger)
+
+	return r
+}
+
+// Resolve resolves program with a new Resolver.  The error is a
+// types.DiagnosticList of every name that could not be resolved.
+func Resolve(program *ast.Program, options Options) error {
+	r := NewResolver(options)
+	r.ResolveProgram(program)
+	return r.diagnostics.Err()
+}
+
+// AddBuiltin declares a builtin procedure and returns its entry.  name
+// is its spelling in the language definition; outside the strict
+// dialect it is looked up in lowercase like every other identifier.
+func (r *Resolver) AddBuiltin(name string, returnType types.STType, argTypes ...types.STType) *types.STEntry {
+	identifier := name
+	if !r.options.CaseSensitive {
+		identifier = strings.ToLower(name)
+	}
+	args := []types.ProcedureArg{}
+	for _, argType := range argTypes {
+		args = append(args, types.ProcedureArg{Type: argType, Mode: types.InMode})
+	}
+	stEntry := &types.STEntry{Identifier: identifier, Name: name, EntryType: types.STProcedure, ProcedureArgTypes: args, ProcedureReturnType: returnType}
+	r.builtins[identifier] = stEntry
+	return stEntry
+}
+
+func (r *Resolver) ReportError(node ast.Node, message string) {
+	r.diagnostics.Add(types.NewDiagnostic(node.Span(), "Name Resolution Error: "+message))
+}
+
+func (r *Resolver) ResolveProgram(program *ast.Program) {
+	// Every name is declared first, then the types of the
+	// declarations filled in, so that the bodies can use any of them
+	for _, decl := range program.Decls {
+		r.Declare(decl, r.globals)
+		if decl, ok := decl.(*ast.VarDecl); ok {
+			r.pending[decl.Name.Entry] = true
+		}
+	}
+	r.hoisting = true
+	r.DeclareNestedGlobals(program.Decls)
+	r.hoisting = false
+	for _, decl := range program.Decls {
+		r.ResolveSignature(decl, r.globals)
+	}
+	for _, decl := range program.Decls {
+		if decl, ok := decl.(*ast.ProcDecl); ok {
+			r.ResolveProcedureBody(decl, r.globals)
+		}
+	}
+	r.ResolveStatements(program.Body, r.globals)
+}
+
+// DeclareNestedGlobals declares the globals declared in the procedures
+// in decls, and in the procedures nested in them.  They are global
+// variables that are initialized after the program's own, so they
+// are pending until their declarations are resolved.
+func (r *Resolver) DeclareNestedGlobals(decls []ast.Decl) {
+	for _, decl := range decls {
+		proc, ok := decl.(*ast.ProcDecl)
+		if !ok {
+			continue
+		}
+		for _, local := range proc.Decls {
+			switch local := local.(type) {
+			case *ast.VarDecl:
+				if local.Global {
+					r.Declare(local, r.globals)
+					r.pending[local.Name.Entry] = true
+				}
+			case *ast.ConstDecl:
+				if local.Global {
+					r.Declare(local, r.globals)
+				}
+			case *ast.ProcDecl:
+				if local.Global {
+					r.Declare(local, r.globals)
+				}
+			case *ast.TypeDecl:
+				if local.Global {
+					r.Declare(local, r.globals)
+				}
+			}
+		}
+		r.DeclareNestedGlobals(proc.Decls)
+	}
+}
+
+// ResolveDeclarations resolves the declarations of a procedure, each
+// of which can only be used by those after it.
+func (r *Resolver) ResolveDeclarations(decls []ast.Decl, s *scope) {
+	for _, decl := range decls {
+		r.Declare(decl, s)
+		r.ResolveSignature(decl, s)
+		if decl, ok := decl.(*ast.ProcDecl); ok {
+			r.ResolveProcedureBody(decl, s)
+		}
+	}
+}
+
+// Declare adds the names declared by decl to s, and to the global
+// scope as well if decl is global.  The entries are completed by
+// ResolveSignature.
+func (r *Resolver) Declare(decl ast.Decl, s *scope) {
+	switch decl := decl.(type) {
+	case *ast.VarDecl:
+		r.AddEntry(decl.Name, &types.STEntry{}, s, decl.Global)
+	case *ast.ConstDecl:
+		r.AddEntry(decl.Name, &types.STEntry{IsConstant: true}, s, decl.Global)
+	case *ast.ProcDecl:
+		r.AddEntry(decl.Name, &types.STEntry{EntryType: types.STProcedure}, s, decl.Global)
+		if s != r.globals || r.hoisting {
+			r.nested[decl.Name.Entry] = true
+		}
+	case *ast.TypeDecl:
+		// The type is incomplete, with no DefinedType, until its
+		// definition has been resolved
+		r.AddEntry(decl.Name, &types.STEntry{EntryType: types.STTypeDefinition, DefinedType: types.STNone}, s, decl.Global)
+		if def, ok := decl.Def.(*ast.EnumDef); ok {
+			enumType := types.EnumType(decl.Name.Name)
+			for ordinal, value := range def.Values {
+				r.AddEntry(value, &types.STEntry{EntryType: enumType, IsEnumValue: true, EnumOrdinal: ordinal}, s, decl.Global)
+			}
+		}
+	}
+}
+
+// AddEntry declares ident as stEntry in s, and in the global scope if
+// global is set, and binds ident to it.  A global declared ahead by
+// DeclareNestedGlobals keeps the entry it was given then.
+func (r *Resolver) AddEntry(ident *ast.Ident, stEntry *types.STEntry, s *scope, global bool) {
+	if r.hoisted[ident] {
+		r.AddLocalEntry(ident, ident.Entry, s)
+		return
+	}
+	stEntry.Identifier = ident.Name
+	stEntry.Name = ident.Spelling
+	ident.Entry = stEntry
+	if r.hoisting {
+		r.hoisted[ident] = true
+	}
+
+	if _, exists := r.builtins[ident.Name]; exists {
+		r.ReportError(ident, "Cannot overload builtin function "+ident.Spelling)
+		return
+	}
+	if global && s != r.globals {
+		if _, exists := r.globals.symbols[ident.Name]; exists {
+			r.ReportError(ident, "The global symbol "+ident.Spelling+" has already been declared")
+			return
+		}
+		r.globals.symbols[ident.Name] = stEntry
+	}
+	r.AddLocalEntry(ident, stEntry, s)
+}
+
+// AddLocalEntry declares ident as stEntry in s alone.
+func (r *Resolver) AddLocalEntry(ident *ast.Ident, stEntry *types.STEntry, s *scope) {
+	if _, exists := s.symbols[ident.Name]; exists {
+		if s == r.globals {
+			r.ReportError(ident, "The global symbol "+ident.Spelling+" has already been declared")
+		} else {
+			r.ReportError(ident, "The local symbol "+ident.Spelling+" has already been declared")
+		}
+		return
+	}
+	s.symbols[ident.Name] = stEntry
+}
+
+// ResolveSignature resolves the types in decl and completes the
+// entries Declare made for it.
+func (r *Resolver) ResolveSignature(decl ast.Decl, s *scope) {
+	switch decl := decl.(type) {
+	case *ast.VarDecl:
+		r.ResolveVariable(decl, s)
+	case *ast.ConstDecl:
+		r.ResolveTypeName(decl.TypeName, &decl.Type, s)
+		decl.Name.Entry.EntryType = decl.Type
+		r.ResolveExpression(decl.Value, s)
+	case *ast.ProcDecl:
+		r.ResolveTypeName(decl.ReturnTypeName, &decl.ReturnType, s)
+		stEntry := decl.Name.Entry
+		stEntry.ProcedureReturnType = decl.ReturnType
+		// Parameters are declared in the procedure's scope by
+		// ResolveProcedureBody, but their types are found here
+		for _, param := range decl.Params {
+			r.ResolveVariable(param, s)
+			stEntry.ProcedureArgTypes = append(stEntry.ProcedureArgTypes, types.ProcedureArg{Type: param.EntryType(), Mode: param.Mode})
+		}
+	case *ast.TypeDecl:
+		stEntry := decl.Name.Entry
+		switch def := decl.Def.(type) {
+		case *ast.EnumDef:
+			for _, value := range def.Values {
+				stEntry.EnumValues = append(stEntry.EnumValues, value.Name)
+			}
+			stEntry.DefinedType = types.EnumType(decl.Name.Name)
+		case *ast.RecordDef:
+			r.declaring = stEntry
+			fields := map[string]bool{}
+			for _, field := range def.Fields {
+				r.ResolveVariable(field, s)
+				if fields[field.Name.Name] {
+					r.ReportError(field.Name, "The field "+field.Name.Spelling+" has already been declared")
+					continue
+				}
+				fields[field.Name.Name] = true
+				stEntry.Fields = append(stEntry.Fields, *field.Name.Entry)
+			}
+			r.declaring = nil
+			stEntry.DefinedType = types.RecordType(decl.Name.Name)
+		}
+	}
+}
+
+// ResolveVariable resolves the type of a variable, parameter or
+// field and completes its entry, making one for a parameter or field,
+// which Declare has not seen.
+func (r *Resolver) ResolveVariable(decl *ast.VarDecl, s *scope) {
+	r.ResolveTypeName(decl.TypeName, &decl.Type, s)
+	stEntry := decl.Name.Entry
+	if stEntry == nil {
+		stEntry = &types.STEntry{Identifier: decl.Name.Name, Name: decl.Name.Spelling}
+		decl.Name.Entry = stEntry
+	}
+	stEntry.EntryType = decl.EntryType()
+	if decl.IsArray() {
+		stEntry.IsArray = true
+		r.ResolveExpression(decl.Bound, s)
+	}
+	if decl.Init != nil {
+		r.initializing = decl
+		r.atStart = s == r.globals || decl.Global
+		r.ResolveExpression(decl.Init, s)
+		r.initializing = nil
+	}
+	delete(r.pending, stEntry)
+}
+
+// CheckInitializerUse reports a variable used in the initializer of
+// r.initializing that has no value yet when the initializer runs: a
+// global declared after it, or for a global declared in a procedure,
+// which is initialized once at program start, any variable that is
+// not global.  An initializer that runs at program start cannot call
+// a procedure declared in a procedure either.
+func (r *Resolver) CheckInitializerUse(ident *ast.Ident, stEntry *types.STEntry) {
+	if stEntry.EntryType == types.STProcedure {
+		if r.atStart && r.nested[stEntry] {
+			r.ReportError(ident, "the initializer of the global variable "+r.initializing.Name.Spelling+" cannot call the nested procedure "+ident.Spelling)
+		}
+		return
+	}
+	if stEntry.IsConstant || stEntry.IsEnumValue || stEntry.EntryType == types.STTypeDefinition {
+		return
+	}
+	if (r.atStart && r.pending[stEntry]) || stEntry == r.initializing.Name.Entry {
+		r.ReportError(ident, "variable "+ident.Spelling+" is used in an initializer before its declaration")
+	} else if r.initializing.Global && r.globals.symbols[ident.Name] != stEntry {
+		r.ReportError(ident, "the initializer of the global variable "+r.initializing.Name.Spelling+" cannot use the local variable "+ident.Spelling)
+	}
+}
+
+// ResolveTypeName sets stType to the type named by typeName, if the
+// type was given by name.
+func (r *Resolver) ResolveTypeName(typeName *ast.Ident, stType *types.STType, s *scope) {
+	if typeName == nil {
+		return
+	}
+	stEntry := r.ResolveIdentifier(typeName, s)
+	if stEntry == nil {
+		return
+	}
+	if stEntry.EntryType != types.STTypeDefinition {
+		r.ReportError(typeName, typeName.Spelling+" is not a type")
+		return
+	}
+	if stEntry == r.declaring {
+		r.ReportError(typeName, "type "+typeName.Spelling+" cannot be used in its own declaration")
+		return
+	}
+	if stEntry.DefinedType == types.STNone {
+		r.ReportError(typeName, "type "+typeName.Spelling+" is used before its declaration")
+		return
+	}
+	*stType = stEntry.DefinedType
+}
+
+// ResolveProcedureBody resolves the parameters, declarations and
+// statements of decl, which is declared in s.
+func (r *Resolver) ResolveProcedureBody(decl *ast.ProcDecl, s *scope) {
+	procScope := &scope{symbols: map[string]*types.STEntry{}, parent: s}
+	// The procedure's own name is local too, so no parameter or local
+	// can take it
+	procScope.symbols[decl.Name.Name] = decl.Name.Entry
+	for _, param := range decl.Params {
+		r.AddEntry(param.Name, param.Name.Entry, procScope, false)
+	}
+	r.ResolveDeclarations(decl.Decls, procScope)
+	r.ResolveStatements(decl.Body, procScope)
+}
+
+// Lookup finds identifier in s and the scopes around it, then among
+// the builtin procedures.
+func (r *Resolver) Lookup(identifier string, s *scope) (*types.STEntry, bool) {
+	for ; s != nil; s = s.parent {
+		if stEntry, exists := s.symbols[identifier]; exists {
+			return stEntry, true
+		}
+	}
+	stEntry, exists := r.builtins[identifier]
+	return stEntry, exists
+}
+
+// ResolveIdentifier binds ident to its declaration, which it returns,
+// or reports it as undeclared and returns nil.
+func (r *Resolver) ResolveIdentifier(ident *ast.Ident, s *scope) *types.STEntry {
+	stEntry, exists := r.Lookup(ident.Name, s)
+	if !exists {
+		r.ReportError(ident, "identifier "+ident.Spelling+" has not been declared")
+		return nil
+	}
+	ident.Entry = stEntry
+	return stEntry
+}
+
+func (r *Resolver) ResolveStatements(stmts []ast.Stmt, s *scope) {
+	for _, stmt := range stmts {
+		r.ResolveStatement(stmt, s)
+	}
+}
+
+func (r *Resolver) ResolveStatement(stmt ast.Stmt, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		r.ResolveExpression(stmt.Target, s)
+		r.ResolveExpression(stmt.Value, s)
+	case *ast.IfStmt:
+		r.ResolveExpression(stmt.Cond, s)
+		r.ResolveStatements(stmt.Then, s)
+		r.ResolveStatements(stmt.Else, s)
+	case *ast.ForStmt:
+		if stmt.Init != nil {
+			r.ResolveStatement(stmt.Init, s)
+		}
+		r.ResolveExpression(stmt.Cond, s)
+		r.ResolveStatements(stmt.Body, s)
+	case *ast.WhileStmt:
+		r.ResolveExpression(stmt.Cond, s)
+		r.ResolveStatements(stmt.Body, s)
+	case *ast.CaseStmt:
+		r.ResolveExpression(stmt.Subject, s)
+		for _, clause := range stmt.Clauses {
+			for _, label := range clause.Labels {
+				r.ResolveExpression(label, s)
+			}
+			r.ResolveStatements(clause.Body, s)
+		}
+		if stmt.Others != nil {
+			r.ResolveStatements(stmt.Others.Body, s)
+		}
+	case *ast.CallStmt:
+		r.ResolveExpression(stmt.Call, s)
+	case *ast.ReturnStmt:
+		r.ResolveExpression(stmt.Value, s)
+	}
+}
+
+func (r *Resolver) ResolveExpression(expr ast.Expr, s *scope) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		stEntry := r.ResolveIdentifier(expr, s)
+		if stEntry != nil && r.initializing != nil {
+			r.CheckInitializerUse(expr, stEntry)
+		}
+	case *ast.IndexExpr:
+		r.ResolveExpression(expr.X, s)
+		r.ResolveExpression(expr.Index, s)
+	case *ast.SelectorExpr:
+		r.ResolveExpression(expr.X, s)
+	case *ast.CallExpr:
+		r.ResolveExpression(expr.Fun, s)
+		for _, arg := range expr.Args {
+			r.ResolveExpression(arg, s)
+		}
+	case *ast.UnaryExpr:
+		r.ResolveExpression(expr.X, s)
+	case *ast.ArrayLit:
+		for _, element := range expr.Elements {
+			r.ResolveExpression(element, s)
+		}
+	case *ast.BinaryExpr:
+		r.ResolveExpression(expr.X, s)
+		r.ResolveExpression(expr.Y, s)
+	}
+}
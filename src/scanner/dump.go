@@ -0,0 +1,21 @@
+package scanner
+
+import (
+	"compiler/src/types"
+	"encoding/json"
+	"io"
+)
+
+// WriteTokensJSON writes one JSON object per line for each token,
+// holding its type, lexeme, value and span.
+func WriteTokensJSON(writer io.Writer, tokenList []types.Token) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, token := range tokenList {
+		err := encoder.Encode(token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
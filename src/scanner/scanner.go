@@ -1,250 +1,584 @@
-// The entry point function is ScanFile
-// The scanner opens a file and reads in
-// the contents.  A token list is created
-// where each token in the list is generated
-// from the file's contents.
+// The scanner turns source text into tokens.  A Lexer is
+// created from an io.Reader, a byte slice or a string and
+// hands out one token at a time through Next and Peek.
+// ScanFile is a convenience that opens a file and returns
+// the complete token list.
 
 package scanner
 
 import (
-	"bufio"
 	"compiler/src/types"
-	"errors"
-	"fmt"
-	"log"
+	"io"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
-func OpenFile(filename string) *os.File {
-	file, err := os.Open(filename)
+// Lexer scans a single source.  Each Lexer owns its own
+// state, so several may be used at the same time.
+type Lexer struct {
+	fileName   string
+	source     []byte
+	options    Options
+	position   types.Position
+	peeked     bool
+	peekTok    types.Token
+	peekErr    error
+	pendingErr error
+	endTrivia  []types.Trivia
+}
+
+// Options selects optional Lexer behaviour.
+type Options struct {
+	// KeepTrivia attaches the whitespace and comments around each
+	// token to it as leading and trailing trivia.
+	KeepTrivia bool
+	// CaseSensitive keeps identifiers exactly as written instead of
+	// folding them to lowercase.  Keywords are matched without
+	// regard to case either way.
+	CaseSensitive bool
+}
+
+// NewLexer reads all of reader and returns a Lexer over it.
+func NewLexer(reader io.Reader, fileName string) (*Lexer, error) {
+	source, err := io.ReadAll(reader)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return file
+	return NewLexerFromBytes(source, fileName), nil
 }
 
-func CloseFile(file *os.File) {
-	file.Close()
+// NewLexerFromBytes returns a Lexer over source.
+func NewLexerFromBytes(source []byte, fileName string) *Lexer {
+	return &Lexer{
+		fileName: fileName,
+		source:   source,
+		position: types.Position{Line: 1, Column: 1, Offset: 0},
+	}
 }
 
-func ScanNextToken(byteScanner *bufio.Scanner, lineCounter *int, blockCommentCounter *int, atNextByte bool) (types.Token, types.ScanCode) {
-	if !atNextByte {
-		for {
-			if byteScanner.Scan() {
-				if byteScanner.Text() != " " && byteScanner.Text() != "\t" {
-					break
-				} else {
-					continue
-				}
-			} else {
-				return types.BuildToken(0, "", types.StopNoTokenScanCode)
-			}
+// NewLexerFromString returns a Lexer over source.
+func NewLexerFromString(source string, fileName string) *Lexer {
+	return NewLexerFromBytes([]byte(source), fileName)
+}
+
+// SetOptions changes how the Lexer scans the tokens it has not
+// yet returned.
+func (lexer *Lexer) SetOptions(options Options) {
+	lexer.options = options
+}
+
+// EndTrivia returns the whitespace and comments after the last
+// token.  It is complete once Next has returned io.EOF.
+func (lexer *Lexer) EndTrivia() []types.Trivia {
+	return lexer.endTrivia
+}
+
+// Next returns the next token.  At the end of the source it
+// returns io.EOF.  Any other error is a types.Diagnostic; the
+// Lexer has already moved past the offending text, so scanning
+// may continue.
+func (lexer *Lexer) Next() (types.Token, error) {
+	if lexer.peeked {
+		lexer.peeked = false
+		return lexer.peekTok, lexer.peekErr
+	}
+	return lexer.scanToken()
+}
+
+// Peek returns the token Next will return without consuming it.
+func (lexer *Lexer) Peek() (types.Token, error) {
+	if !lexer.peeked {
+		lexer.peekTok, lexer.peekErr = lexer.scanToken()
+		lexer.peeked = true
+	}
+	return lexer.peekTok, lexer.peekErr
+}
+
+// ScanAll returns every remaining token, ending with a
+// types.EOFToken that holds the end trivia.  Scanning continues past
+// lexical errors; if there were any they are returned together as
+// a types.DiagnosticList.
+func (lexer *Lexer) ScanAll() ([]types.Token, error) {
+	var tokenList []types.Token
+	var diagnostics types.DiagnosticList
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			tokenList = append(tokenList, lexer.eofToken())
+			return tokenList, diagnostics.Err()
+		}
+		if err != nil {
+			diagnostics.Add(err)
+			continue
 		}
+		tokenList = append(tokenList, token)
+	}
+}
+
+// eofToken returns the token marking the end of the source.
+func (lexer *Lexer) eofToken() types.Token {
+	token := types.Token{TokenType: types.EOFToken, IntValue: -1, FloatValue: -1, Span: lexer.spanFrom(lexer.position)}
+	if lexer.options.KeepTrivia {
+		token.LeadingTrivia = lexer.endTrivia
 	}
+	return token
+}
 
-	word := ""
-	firstByte := byteScanner.Text()
-	firstByteAsRune, _ := utf8.DecodeRuneInString(firstByte)
+// peekRune decodes the rune ahead bytes past the current position
+// without consuming it.  It returns utf8.RuneError and a size of 0
+// at the end of the source.
+func (lexer *Lexer) peekRune(ahead int) (rune, int) {
+	offset := lexer.position.Offset + ahead
+	if offset >= len(lexer.source) {
+		return utf8.RuneError, 0
+	}
+	return utf8.DecodeRune(lexer.source[offset:])
+}
 
-	if firstByte == "\n" {
-		return types.BuildToken(0, "", types.NewlineScanCode)
-	} else if unicode.IsLetter(firstByteAsRune) {
-		word += strings.ToLower(firstByte)
-		for {
-			if byteScanner.Scan() {
-				nextByte := byteScanner.Text()
-				nextByteAsRune, _ := utf8.DecodeRuneInString(nextByte)
-				if unicode.IsLetter(nextByteAsRune) || unicode.IsDigit(nextByteAsRune) || nextByte == "_" {
-					word += strings.ToLower(nextByte)
-				} else if nextByte == " " || nextByte == "\t" {
-					break
-				} else if nextByte == "\n" {
-					return types.BuildTokenFromAlphaNumeric(*lineCounter, word, types.AtNextByteScanCode)
-				} else {
-					return types.BuildTokenFromAlphaNumeric(*lineCounter, word, types.AtNextByteScanCode)
-				}
-			} else {
-				return types.BuildTokenFromAlphaNumeric(*lineCounter, word, types.StopWithTokenScanCode)
+// readRune consumes the next rune and advances the position.
+func (lexer *Lexer) readRune() rune {
+	r, size := lexer.peekRune(0)
+	if size == 0 {
+		return r
+	}
+	lexer.position.Offset += size
+	if r == '\n' {
+		lexer.position.Line++
+		lexer.position.Column = 1
+	} else {
+		lexer.position.Column++
+	}
+	return r
+}
+
+func (lexer *Lexer) atEnd() bool {
+	return lexer.position.Offset >= len(lexer.source)
+}
+
+// spanFrom returns the span from start up to the current position.
+func (lexer *Lexer) spanFrom(start types.Position) types.Span {
+	return types.Span{FileName: lexer.fileName, Start: start, End: lexer.position}
+}
+
+func (lexer *Lexer) text(start types.Position) string {
+	return string(lexer.source[start.Offset:lexer.position.Offset])
+}
+
+// scanTrivia reads the whitespace and comments in front of the
+// next token.  When stopAtNewline is set it stops after the first
+// newline, which is how trailing trivia is split from the leading
+// trivia of the following token.  It returns an error for a block
+// comment that is still open at the end of the source.
+func (lexer *Lexer) scanTrivia(stopAtNewline bool) ([]types.Trivia, error) {
+	var trivia []types.Trivia
+	for !lexer.atEnd() {
+		start := lexer.position
+		r, _ := lexer.peekRune(0)
+		next, _ := lexer.peekRune(1)
+		var kind types.TriviaKind
+		if r == ' ' || r == '\t' || r == '\r' {
+			for r == ' ' || r == '\t' || r == '\r' {
+				lexer.readRune()
+				r, _ = lexer.peekRune(0)
+			}
+			kind = types.WhitespaceTrivia
+		} else if r == '\n' {
+			lexer.readRune()
+			kind = types.NewlineTrivia
+		} else if r == '/' && next == '/' {
+			for !lexer.atEnd() && r != '\n' {
+				lexer.readRune()
+				r, _ = lexer.peekRune(0)
 			}
+			kind = types.LineCommentTrivia
+		} else if r == '/' && next == '*' {
+			err := lexer.skipBlockComment()
+			if err != nil {
+				return trivia, err
+			}
+			kind = types.BlockCommentTrivia
+		} else {
+			break
 		}
-		return types.BuildTokenFromAlphaNumeric(*lineCounter, word, types.TokenScanCode)
-	} else if unicode.IsDigit(firstByteAsRune) {
-		word += firstByte
-		inDecimal := false
-		for {
-			if byteScanner.Scan() {
-				nextByte := byteScanner.Text()
-				nextByteAsRune, _ := utf8.DecodeRuneInString(nextByte)
-				if unicode.IsDigit(nextByteAsRune) || nextByte == "_" {
-					word += strings.ToLower(nextByte)
-				} else if nextByte == "." && !inDecimal {
-					word += strings.ToLower(nextByte)
-					inDecimal = true
-				} else if nextByte == " " || nextByte == "\t" {
-					break
-				} else if nextByte == "\n" {
-					return types.BuildTokenFromNumeric(*lineCounter, word, types.AtNextByteScanCode)
-				} else {
-					return types.BuildTokenFromNumeric(*lineCounter, word, types.AtNextByteScanCode)
-				}
-			} else {
-				return types.BuildTokenFromNumeric(*lineCounter, word, types.StopWithTokenScanCode)
+		trivia = append(trivia, types.Trivia{Kind: kind, Text: lexer.text(start), Span: lexer.spanFrom(start)})
+		if stopAtNewline && kind == types.NewlineTrivia {
+			break
+		}
+	}
+	return trivia, nil
+}
+
+func (lexer *Lexer) skipBlockComment() error {
+	start := lexer.position
+	depth := 0
+	for !lexer.atEnd() {
+		r, _ := lexer.peekRune(0)
+		next, _ := lexer.peekRune(1)
+		if r == '/' && next == '*' {
+			lexer.readRune()
+			lexer.readRune()
+			depth++
+		} else if r == '*' && next == '/' {
+			lexer.readRune()
+			lexer.readRune()
+			depth--
+			if depth == 0 {
+				return nil
 			}
+		} else {
+			lexer.readRune()
 		}
-		return types.BuildTokenFromNumeric(*lineCounter, word, types.TokenScanCode)
-	} else if firstByte == "\"" {
+	}
+	span := types.Span{FileName: lexer.fileName, Start: start, End: symbolEnd(start, "/*")}
+	return ScanError(span, "unterminated block comment")
+}
+
+// symbolEnd returns the position just past symbol when it starts at start.
+func symbolEnd(start types.Position, symbol string) types.Position {
+	return types.Position{Line: start.Line, Column: start.Column + len([]rune(symbol)), Offset: start.Offset + len(symbol)}
+}
+
+func (lexer *Lexer) scanToken() (types.Token, error) {
+	if lexer.pendingErr != nil {
+		err := lexer.pendingErr
+		lexer.pendingErr = nil
+		return types.Token{}, err
+	}
+
+	leading, err := lexer.scanTrivia(false)
+	if err != nil {
+		return types.Token{}, err
+	}
+	if lexer.atEnd() {
+		lexer.endTrivia = append(lexer.endTrivia, leading...)
+		return types.Token{}, io.EOF
+	}
+
+	start := lexer.position
+	token, err := lexer.scanLexeme(start)
+	token.Lexeme = lexer.text(start)
+
+	// An unterminated block comment after the token is reported by
+	// the next call so the token itself is not lost.
+	trailing, trailingErr := lexer.scanTrivia(true)
+	lexer.pendingErr = trailingErr
+	if lexer.options.KeepTrivia {
+		token.LeadingTrivia = leading
+		token.TrailingTrivia = trailing
+	}
+	return token, err
+}
+
+// scanLexeme scans the token starting at start.
+func (lexer *Lexer) scanLexeme(start types.Position) (types.Token, error) {
+	if lexer.atNumberStart() {
+		return lexer.scanNumber(start)
+	}
+
+	firstRune := lexer.readRune()
+	if unicode.IsLetter(firstRune) {
 		for {
-			if byteScanner.Scan() {
-				nextByte := byteScanner.Text()
-				if nextByte == "\"" {
-					return types.BuildTokenFromString(*lineCounter, word, types.TokenScanCode)
-				} else if nextByte == "\n" {
-					*lineCounter++
-					word += nextByte
-				} else {
-					word += nextByte
-				}
-			} else {
-				return types.BuildTokenFromString(*lineCounter, firstByte+word, types.ErrorScanCode)
+			r, size := lexer.peekRune(0)
+			if size == 0 || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+				break
 			}
+			lexer.readRune()
 		}
-	} else if firstByte == ":" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "=" {
-				return types.BuildTokenFromSymbol(*lineCounter, firstByte+nextByte, types.TokenScanCode)
-			}
+		word := lexer.text(start)
+		if _, isKeyword := types.KeywordTokenTypeMap[strings.ToLower(word)]; isKeyword || !lexer.options.CaseSensitive {
+			word = strings.ToLower(word)
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == "<" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "=" {
-				return types.BuildTokenFromSymbol(*lineCounter, firstByte+nextByte, types.TokenScanCode)
-			}
+		return lexer.finish(types.BuildTokenFromAlphaNumeric(lexer.spanFrom(start), word, types.TokenScanCode))
+	} else if firstRune == '"' {
+		return lexer.scanString(start)
+	}
+
+	next, _ := lexer.peekRune(0)
+	if (firstRune == ':' || firstRune == '<' || firstRune == '>' || firstRune == '=' || firstRune == '!') && next == '=' {
+		lexer.readRune()
+	} else if firstRune == '*' && next == '/' {
+		lexer.readRune()
+		return types.Token{}, ScanError(lexer.spanFrom(start), "unbalanced \"*/\" outside of a block comment")
+	}
+	return lexer.finish(types.BuildTokenFromSymbol(lexer.spanFrom(start), lexer.text(start), types.TokenScanCode))
+}
+
+// atNumberStart reports whether the next rune begins a numeric
+// literal.  A leading '.' only does so when a digit follows it.
+func (lexer *Lexer) atNumberStart() bool {
+	r, _ := lexer.peekRune(0)
+	next, _ := lexer.peekRune(1)
+	if r >= '0' && r <= '9' {
+		return true
+	}
+	return r == '.' && next >= '0' && next <= '9'
+}
+
+// scanNumber scans an integer literal (decimal, or 0x, 0b and 0o
+// prefixed) or a decimal float literal with an optional exponent.
+// Any letters, digits or underscores directly following the literal
+// are treated as part of it so that the whole literal is reported.
+func (lexer *Lexer) scanNumber(start types.Position) (types.Token, error) {
+	base := 10
+	first, _ := lexer.peekRune(0)
+	prefix, _ := lexer.peekRune(1)
+	if first == '0' && strings.ContainsRune("xXbBoO", prefix) {
+		lexer.readRune()
+		lexer.readRune()
+		base = map[rune]int{'x': 16, 'b': 2, 'o': 8}[unicode.ToLower(prefix)]
+	}
+
+	isFloat := false
+	if base == 10 {
+		lexer.skipDigits()
+		if r, _ := lexer.peekRune(0); r == '.' {
+			lexer.readRune()
+			isFloat = true
+			lexer.skipDigits()
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == ">" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "=" {
-				return types.BuildTokenFromSymbol(*lineCounter, firstByte+nextByte, types.TokenScanCode)
+		r, _ := lexer.peekRune(0)
+		if r == 'e' || r == 'E' {
+			lexer.readRune()
+			isFloat = true
+			if sign, _ := lexer.peekRune(0); sign == '+' || sign == '-' {
+				lexer.readRune()
 			}
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == "=" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "=" {
-				return types.BuildTokenFromSymbol(*lineCounter, firstByte+nextByte, types.TokenScanCode)
-			}
+	}
+	for {
+		r, size := lexer.peekRune(0)
+		if size == 0 || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			break
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == "!" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "=" {
-				return types.BuildTokenFromSymbol(*lineCounter, firstByte+nextByte, types.TokenScanCode)
-			}
+		lexer.readRune()
+	}
+
+	span := lexer.spanFrom(start)
+	word := lexer.text(start)
+	if isFloat {
+		return lexer.buildFloat(span, word)
+	}
+	return lexer.buildInteger(span, word, base)
+}
+
+func (lexer *Lexer) skipDigits() {
+	for {
+		r, _ := lexer.peekRune(0)
+		if !(r >= '0' && r <= '9') && r != '_' {
+			return
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == "/" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "/" {
-				return types.BuildToken(*lineCounter, firstByte+nextByte, types.LineCommentScanCode)
-			} else if nextByte == "*" {
-				return types.BuildToken(*lineCounter, firstByte+nextByte, types.BlockCommentOpenScanCode)
-			}
+		lexer.readRune()
+	}
+}
+
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hexadecimal"}
+
+func (lexer *Lexer) buildInteger(span types.Span, word string, base int) (types.Token, error) {
+	digits := word
+	if base != 10 {
+		digits = word[2:]
+	}
+	if digits == "" {
+		return types.Token{}, ScanError(span, baseNames[base]+" literal "+word+" has no digits")
+	}
+	for _, r := range digits {
+		if r == '_' {
+			continue
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else if firstByte == "*" {
-		if byteScanner.Scan() {
-			nextByte := byteScanner.Text()
-			if nextByte == "/" {
-				return types.BuildToken(*lineCounter, firstByte+nextByte, types.BlockCommentCloseScanCode)
-			}
+		if value, ok := hexDigitValue(r); !ok || int(value) >= base {
+			return types.Token{}, ScanError(span, "invalid digit '"+string(r)+"' in "+baseNames[base]+" literal "+word)
 		}
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
-	} else {
-		return types.BuildTokenFromSymbol(*lineCounter, firstByte, types.TokenScanCode)
 	}
+	if !separatorsValid(digits, func(r rune) bool { return r != '_' }) {
+		return types.Token{}, ScanError(span, "'_' must separate successive digits in "+word)
+	}
+
+	value, err := strconv.ParseUint(strings.ReplaceAll(digits, "_", ""), base, 64)
+	if err != nil || value > math.MaxInt64 {
+		return types.Token{}, ScanError(span, "integer literal "+word+" is out of range")
+	}
+	return lexer.finish(types.BuildTokenFromInteger(span, int64(value), types.TokenScanCode))
 }
 
-func ScanErrorString(token types.Token) string {
-	return "Error: Ln:" + fmt.Sprint(token.LineNumber)
+func (lexer *Lexer) buildFloat(span types.Span, word string) (types.Token, error) {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	if !separatorsValid(word, isDigit) {
+		return types.Token{}, ScanError(span, "'_' must separate successive digits in "+word)
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(word, "_", ""), 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return types.Token{}, ScanError(span, "float literal "+word+" is out of range")
+	} else if err != nil {
+		return types.Token{}, ScanError(span, "malformed float literal "+word)
+	}
+	return lexer.finish(types.BuildTokenFromFloat(span, value, types.TokenScanCode))
 }
 
-func ScanFile(filename string) []types.Token {
-	file := OpenFile(filename)
-	defer CloseFile(file)
-	blockCommentCounter := 0
-	var tokenList []types.Token
-	byteScanner := bufio.NewScanner(file)
-	byteScanner.Split(bufio.ScanRunes)
-	lineCounter := 1
-	skipLine := false
+// separatorsValid reports whether every '_' in word sits between two digits.
+func separatorsValid(word string, isDigit func(rune) bool) bool {
+	runes := []rune(word)
+	for i, r := range runes {
+		if r == '_' && (i == 0 || i == len(runes)-1 || !isDigit(runes[i-1]) || !isDigit(runes[i+1])) {
+			return false
+		}
+	}
+	return true
+}
 
-	atNextByte := false
+// scanString scans the rest of a string literal whose opening quote
+// starts at start, decoding escape sequences into the token value.
+// A string literal ends at the end of its line, so a missing closing
+// quote is reported at the opening one and scanning carries on with
+// the next line.
+func (lexer *Lexer) scanString(start types.Position) (types.Token, error) {
+	var word strings.Builder
+	var escapeErr error
 	for {
-		token, code := ScanNextToken(byteScanner, &lineCounter, &blockCommentCounter, atNextByte)
-		atNextByte = false
-		if code == types.TokenScanCode {
-			if !skipLine && blockCommentCounter == 0 {
-				tokenList = append(tokenList, token)
-			}
-		} else if code == types.AtNextByteScanCode {
-			if !skipLine && blockCommentCounter == 0 {
-				tokenList = append(tokenList, token)
-			}
-			atNextByte = true
-		} else if code == types.NewlineScanCode {
-			lineCounter++
-			if skipLine {
-				skipLine = false
-			}
-		} else if code == types.StopWithTokenScanCode {
-			if !skipLine && blockCommentCounter == 0 {
-				tokenList = append(tokenList, token)
-			}
+		if r, size := lexer.peekRune(0); size == 0 || r == '\n' {
 			break
-		} else if code == types.StopNoTokenScanCode {
-			break
-		} else if code == types.LineCommentScanCode {
-			if blockCommentCounter == 0 {
-				skipLine = true
+		}
+		escapeStart := lexer.position
+		r := lexer.readRune()
+		if r == '"' {
+			token, err := lexer.finish(types.BuildTokenFromString(lexer.spanFrom(start), word.String(), types.TokenScanCode))
+			if escapeErr != nil {
+				return token, escapeErr
 			}
-		} else if code == types.BlockCommentOpenScanCode {
-			blockCommentCounter++
-		} else if code == types.BlockCommentCloseScanCode {
-			blockCommentCounter--
-		} else if code == types.ErrorScanCode {
-			if !skipLine && blockCommentCounter == 0 {
-				fmt.Println(errors.New(ScanErrorString(token)))
-				break
+			return token, err
+		} else if r == '\\' {
+			decoded, err := lexer.scanEscape(escapeStart)
+			if err != nil && escapeErr == nil {
+				escapeErr = err
 			}
+			word.WriteRune(decoded)
+		} else {
+			word.WriteRune(r)
 		}
 	}
+	quote := types.Span{FileName: lexer.fileName, Start: start, End: symbolEnd(start, "\"")}
+	return types.Token{}, ScanError(quote, "unterminated string")
+}
 
-	return tokenList
+// scanEscape decodes the escape sequence following a backslash at start.
+// Supported escapes are \n, \t, \\, \" and \u{hex}.  A backslash at
+// the end of a line leaves the newline to end the string.
+func (lexer *Lexer) scanEscape(start types.Position) (rune, error) {
+	if r, size := lexer.peekRune(0); size == 0 || r == '\n' {
+		return utf8.RuneError, ScanError(lexer.spanFrom(start), "unterminated escape sequence")
+	}
+	r := lexer.readRune()
+	switch r {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case '\\':
+		return '\\', nil
+	case '"':
+		return '"', nil
+	case 'u':
+		return lexer.scanUnicodeEscape(start)
+	}
+	return r, ScanError(lexer.spanFrom(start), "unknown escape sequence \\"+string(r))
 }
 
-func PrintTokenList(tokenList []types.Token) {
-	for _, value := range tokenList {
-		if value.TokenType == types.IdentifierToken {
-			print("Ln:", value.LineNumber, " | ", value.TokenType, " | ", value.StringValue)
-		} else if value.TokenType == types.IntegerToken {
-			print("Ln:", value.LineNumber, " | ", value.TokenType, " | ", value.IntValue)
-		} else if value.TokenType == types.FloatToken {
-			print("Ln:", value.LineNumber, " | ", value.TokenType, " | ", value.FloatValue)
-		} else if value.TokenType == types.StringToken {
-			print("Ln:", value.LineNumber, " | ", value.TokenType, " | ", value.StringValue)
-		} else {
-			print("Ln:", value.LineNumber, " | ", value.TokenType, " | ", value.StringValue)
+// scanUnicodeEscape decodes the {hex} part of a \u{hex} escape.
+func (lexer *Lexer) scanUnicodeEscape(start types.Position) (rune, error) {
+	errString := "invalid unicode escape, expected \\u{hex digits}"
+	if r, _ := lexer.peekRune(0); r != '{' {
+		return utf8.RuneError, ScanError(lexer.spanFrom(start), errString)
+	}
+	lexer.readRune()
+
+	var value rune
+	digits := 0
+	for {
+		r, size := lexer.peekRune(0)
+		if size == 0 || r == '"' || r == '\n' {
+			return utf8.RuneError, ScanError(lexer.spanFrom(start), errString)
+		}
+		lexer.readRune()
+		if r == '}' {
+			break
+		}
+		digit, ok := hexDigitValue(r)
+		if !ok {
+			return utf8.RuneError, ScanError(lexer.spanFrom(start), errString)
+		}
+		digits++
+		if digits <= 6 {
+			value = value*16 + digit
+		}
+	}
+
+	if digits == 0 || digits > 6 || value > unicode.MaxRune || (value >= 0xD800 && value <= 0xDFFF) {
+		return utf8.RuneError, ScanError(lexer.spanFrom(start), "unicode escape \""+lexer.text(start)+"\" is not a valid code point")
+	}
+	return value, nil
+}
+
+func hexDigitValue(r rune) (rune, bool) {
+	if r >= '0' && r <= '9' {
+		return r - '0', true
+	} else if r >= 'a' && r <= 'f' {
+		return r - 'a' + 10, true
+	} else if r >= 'A' && r <= 'F' {
+		return r - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// finish turns the scan code reported by the types.BuildToken
+// functions into an error.
+func (lexer *Lexer) finish(token types.Token, scanCode types.ScanCode) (types.Token, error) {
+	if scanCode == types.ErrorScanCode {
+		return token, ScanError(token.Span, "illegal character "+strconv.Quote(lexer.text(token.Span.Start)))
+	}
+	return token, nil
+}
+
+// ScanError returns a lexical error located at span.
+func ScanError(span types.Span, message string) error {
+	return types.NewDiagnostic(span, "Error: "+message)
+}
+
+// ScanFile scans the file at filename with the given options.  It returns every token
+// that could be read along with any lexical diagnostics, leaving
+// the caller to decide whether to go on and parse.
+func ScanFile(filename string, options Options) ([]types.Token, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lexer, err := NewLexer(file, filename)
+	if err != nil {
+		return nil, err
+	}
+	lexer.SetOptions(options)
+	return lexer.ScanAll()
+}
+
+// SourceText rebuilds the scanned source from tokens returned by
+// ScanAll with KeepTrivia set.  For a source without lexical errors
+// the result is identical to the original.
+func SourceText(tokenList []types.Token) string {
+	var text strings.Builder
+	writeTrivia := func(trivia []types.Trivia) {
+		for _, piece := range trivia {
+			text.WriteString(piece.Text)
 		}
-		print("\n")
 	}
+	for _, token := range tokenList {
+		writeTrivia(token.LeadingTrivia)
+		text.WriteString(token.Lexeme)
+		writeTrivia(token.TrailingTrivia)
+	}
+	return text.String()
 }
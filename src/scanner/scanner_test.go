@@ -0,0 +1,262 @@
+package scanner
+
+import (
+	"compiler/src/types"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// describe writes out a token as its type and value.
+func describe(token types.Token) string {
+	switch token.TokenType {
+	case types.IntegerToken:
+		return "int " + strconv.FormatInt(token.IntValue, 10)
+	case types.FloatToken:
+		return "float " + strconv.FormatFloat(token.FloatValue, 'g', -1, 64)
+	case types.StringToken:
+		return "string " + strconv.Quote(token.StringValue)
+	case types.IdentifierToken:
+		return "id " + token.StringValue
+	}
+	return string(token.TokenType)
+}
+
+// scan returns the tokens of source, without the EOF token, and the
+// messages of any lexical errors.
+func scan(t *testing.T, source string, options Options) ([]types.Token, []string) {
+	t.Helper()
+	lexer := NewLexerFromString(source, "test")
+	lexer.SetOptions(options)
+	tokenList, err := lexer.ScanAll()
+	if len(tokenList) == 0 || tokenList[len(tokenList)-1].TokenType != types.EOFToken {
+		t.Fatalf("%q: token list does not end with an EOF token", source)
+	}
+	var messages []string
+	if err != nil {
+		list, ok := err.(types.DiagnosticList)
+		if !ok {
+			t.Fatalf("%q: error is a %T, not a types.DiagnosticList", source, err)
+		}
+		for _, diagnostic := range list {
+			messages = append(messages, diagnostic.Error())
+		}
+	}
+	return tokenList[:len(tokenList)-1], messages
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"", nil},
+		{"x := y1_a;", []string{"id x", ":=", "id y1_a", ";"}},
+		{"<= >= == != < > + - * / & |", []string{"<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "&", "|"}},
+		{"Program BEGIN end", []string{"program", "begin", "end"}},
+		{"MixedCase", []string{"id mixedcase"}},
+		{"a.b[1]", []string{"id a", ".", "id b", "[", "int 1", "]"}},
+
+		// Integer literals
+		{"0 42 1_000_000", []string{"int 0", "int 42", "int 1000000"}},
+		{"0xff 0XFF 0x7fff_ffff", []string{"int 255", "int 255", "int 2147483647"}},
+		{"0b101 0B1_0", []string{"int 5", "int 2"}},
+		{"0o17 0O7_7", []string{"int 15", "int 63"}},
+		{"9223372036854775807", []string{"int 9223372036854775807"}},
+
+		// Float literals
+		{"1.5 .25 3.", []string{"float 1.5", "float 0.25", "float 3"}},
+		{"1e3 2.5E-2 1_0.0_1e+1_0", []string{"float 1000", "float 0.025", "float 1.001e+11"}},
+
+		// String literals and escapes
+		{`"hello"`, []string{`string "hello"`}},
+		{`"a\nb\tc\\d\"e"`, []string{`string "a\nb\tc\\d\"e"`}},
+		{`"\u{41}\u{e9}\u{1F600}"`, []string{`string "Aé😀"`}},
+		{`"" "x"`, []string{`string ""`, `string "x"`}},
+
+		// Comments
+		{"a // b c\nd", []string{"id a", "id d"}},
+		{"a /* b */ c", []string{"id a", "id c"}},
+		{"a /* b /* c */ d */ e", []string{"id a", "id e"}},
+		{"a/**/b", []string{"id a", "id b"}},
+	}
+	for _, test := range tests {
+		tokenList, messages := scan(t, test.source, Options{})
+		if len(messages) > 0 {
+			t.Errorf("%q: unexpected errors %v", test.source, messages)
+			continue
+		}
+		var got []string
+		for _, token := range tokenList {
+			got = append(got, describe(token))
+		}
+		if strings.Join(got, " | ") != strings.Join(test.want, " | ") {
+			t.Errorf("%q: got %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestCaseSensitive(t *testing.T) {
+	tokenList, _ := scan(t, "MixedCase BEGIN", Options{CaseSensitive: true})
+	if got := describe(tokenList[0]) + " " + describe(tokenList[1]); got != "id MixedCase begin" {
+		t.Errorf("got %s, want id MixedCase begin", got)
+	}
+}
+
+// TestErrors checks the lexical errors reported for each source.
+// Scanning goes on after an error, so every error in a source is
+// reported, and the tokens around them are kept.
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+		tokens []string
+	}{
+		{"a $ b", []string{`test:1:3: Error: illegal character "$"`}, []string{"id a", "id b"}},
+		{"$ # @", []string{
+			`test:1:1: Error: illegal character "$"`,
+			`test:1:3: Error: illegal character "#"`,
+			`test:1:5: Error: illegal character "@"`,
+		}, nil},
+		{"a = b ! c", []string{
+			`test:1:3: Error: illegal character "="`,
+			`test:1:7: Error: illegal character "!"`,
+		}, []string{"id a", "id b", "id c"}},
+
+		// Numbers
+		{"0x", []string{"test:1:1: Error: hexadecimal literal 0x has no digits"}, nil},
+		{"0b102", []string{"test:1:1: Error: invalid digit '2' in binary literal 0b102"}, nil},
+		{"0o8", []string{"test:1:1: Error: invalid digit '8' in octal literal 0o8"}, nil},
+		{"0xfg", []string{"test:1:1: Error: invalid digit 'g' in hexadecimal literal 0xfg"}, nil},
+		{"12a", []string{"test:1:1: Error: invalid digit 'a' in decimal literal 12a"}, nil},
+		{"1__0", []string{"test:1:1: Error: '_' must separate successive digits in 1__0"}, nil},
+		{"10_", []string{"test:1:1: Error: '_' must separate successive digits in 10_"}, nil},
+		{"0x_1", []string{"test:1:1: Error: '_' must separate successive digits in 0x_1"}, nil},
+		{"1_.5", []string{"test:1:1: Error: '_' must separate successive digits in 1_.5"}, nil},
+		{"1e_5", []string{"test:1:1: Error: '_' must separate successive digits in 1e_5"}, nil},
+		{"1e", []string{"test:1:1: Error: malformed float literal 1e"}, nil},
+		{"9223372036854775808", []string{"test:1:1: Error: integer literal 9223372036854775808 is out of range"}, nil},
+		{"1e999", []string{"test:1:1: Error: float literal 1e999 is out of range"}, nil},
+
+		// Strings
+		{`"abc`, []string{"test:1:1: Error: unterminated string"}, nil},
+		{"x := \"abc;\ny := 1 $;", []string{
+			"test:1:6: Error: unterminated string",
+			`test:2:8: Error: illegal character "$"`,
+		}, []string{"id x", ":=", "id y", ":=", "int 1", ";"}},
+		{"\"abc\\\nd\"", []string{
+			"test:1:1: Error: unterminated string",
+			"test:2:2: Error: unterminated string",
+		}, []string{"id d"}},
+		{`"\q" "ok"`, []string{`test:1:2: Error: unknown escape sequence \q`}, []string{`string "ok"`}},
+		{`"\u{}"`, []string{`test:1:2: Error: unicode escape "\u{}" is not a valid code point`}, nil},
+		{`"\u{d800}"`, []string{`test:1:2: Error: unicode escape "\u{d800}" is not a valid code point`}, nil},
+		{`"\u41"`, []string{`test:1:2: Error: invalid unicode escape, expected \u{hex digits}`}, nil},
+
+		// Comments
+		{"a /* b", []string{"test:1:3: Error: unterminated block comment"}, []string{"id a"}},
+		{"/* a /* b */", []string{"test:1:1: Error: unterminated block comment"}, nil},
+		{"a */ b", []string{`test:1:3: Error: unbalanced "*/" outside of a block comment`}, []string{"id a", "id b"}},
+	}
+	for _, test := range tests {
+		tokenList, messages := scan(t, test.source, Options{})
+		if strings.Join(messages, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: got errors\n%s\nwant\n%s", test.source, strings.Join(messages, "\n"), strings.Join(test.want, "\n"))
+		}
+		var got []string
+		for _, token := range tokenList {
+			got = append(got, describe(token))
+		}
+		if strings.Join(got, " | ") != strings.Join(test.tokens, " | ") {
+			t.Errorf("%q: got tokens %v, want %v", test.source, got, test.tokens)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	tokenList, _ := scan(t, "ab :=\n  \"é\" 12", Options{})
+	want := []string{"test:1:1", "test:1:4", "test:2:3", "test:2:7"}
+	for i, token := range tokenList {
+		got := token.Span.FileName + ":" + strconv.Itoa(token.Span.Start.Line) + ":" + strconv.Itoa(token.Span.Start.Column)
+		if got != want[i] {
+			t.Errorf("token %d %q starts at %s, want %s", i, token.Lexeme, got, want[i])
+		}
+	}
+	if lexeme := tokenList[2].Lexeme; lexeme != `"é"` {
+		t.Errorf("got lexeme %s, want \"é\"", lexeme)
+	}
+}
+
+// TestTrivia checks that with KeepTrivia the comments and whitespace
+// on a token's line after it are its trailing trivia and the rest are
+// the leading trivia of the next token, or of the EOF token.
+func TestTrivia(t *testing.T) {
+	kinds := func(trivia []types.Trivia) string {
+		var words []string
+		for _, piece := range trivia {
+			words = append(words, string(piece.Kind)+" "+strconv.Quote(piece.Text))
+		}
+		return strings.Join(words, ", ")
+	}
+	lexer := NewLexerFromString("// head\na /* x /* y */ */ // tail\n\nb\n// end\n", "test")
+	lexer.SetOptions(Options{KeepTrivia: true})
+	tokenList, err := lexer.ScanAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		what string
+		got  string
+		want string
+	}{
+		{"a leading", kinds(tokenList[0].LeadingTrivia), `line_comment "// head", newline "\n"`},
+		{"a trailing", kinds(tokenList[0].TrailingTrivia), `whitespace " ", block_comment "/* x /* y */ */", whitespace " ", line_comment "// tail", newline "\n"`},
+		{"b leading", kinds(tokenList[1].LeadingTrivia), `newline "\n"`},
+		{"b trailing", kinds(tokenList[1].TrailingTrivia), `newline "\n"`},
+		{"EOF leading", kinds(tokenList[2].LeadingTrivia), `line_comment "// end", newline "\n"`},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %s, want %s", test.what, test.got, test.want)
+		}
+	}
+	if text := SourceText(tokenList); text != "// head\na /* x /* y */ */ // tail\n\nb\n// end\n" {
+		t.Errorf("SourceText gave %q", text)
+	}
+}
+
+func TestPeekAndNext(t *testing.T) {
+	lexer := NewLexerFromString("a b", "test")
+	for _, want := range []string{"id a", "id b"} {
+		peeked, err := lexer.Peek()
+		if err != nil || describe(peeked) != want {
+			t.Fatalf("Peek gave %s, %v, want %s", describe(peeked), err, want)
+		}
+		next, err := lexer.Next()
+		if err != nil || describe(next) != want {
+			t.Fatalf("Next gave %s, %v, want %s", describe(next), err, want)
+		}
+	}
+	if _, err := lexer.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestIndependentLexers(t *testing.T) {
+	first := NewLexerFromString("a b", "first")
+	second := NewLexerFromString("c d", "second")
+	var got []string
+	for i := 0; i < 2; i++ {
+		for _, lexer := range []*Lexer{first, second} {
+			token, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, describe(token)+"@"+token.Span.FileName)
+		}
+	}
+	if strings.Join(got, " ") != "id a@first id c@second id b@first id d@second" {
+		t.Errorf("got %v", got)
+	}
+}
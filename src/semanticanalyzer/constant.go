@@ -0,0 +1,317 @@
+// Compile time evaluation of constant expressions.
+// A constant expression is built from literals, enum values, other
+// constants and the operators.  It is evaluated with the same rules
+// as the generated code would use: integer division truncates, &, |
+// and not on integers work bit by bit, and a bool compared with an
+// integer sees the integer as a bool.  Constants are evaluated when
+// first needed, so a global constant may be defined in terms of one
+// declared after it.
+
+package semanticanalyzer
+
+import (
+	"compiler/src/ast"
+	"compiler/src/types"
+	"strconv"
+	"strings"
+)
+
+// constant is a constant declaration and how far its evaluation has
+// got.
+type constant struct {
+	decl       *ast.ConstDecl
+	evaluating bool
+	done       bool
+}
+
+// FindConstants records the constant declarations in decls and the
+// procedures declared there, so that they can be evaluated before
+// their declaration is reached.
+func (a *Analyzer) FindConstants(decls []ast.Decl) {
+	for _, decl := range decls {
+		switch decl := decl.(type) {
+		case *ast.ConstDecl:
+			a.constants[decl.Name.Entry] = &constant{decl: decl}
+		case *ast.ProcDecl:
+			a.FindConstants(decl.Decls)
+		}
+	}
+}
+
+// ConstantValue returns the value of the constant declared as
+// stEntry, evaluating it if that has not been done yet.
+func (a *Analyzer) ConstantValue(stEntry *types.STEntry) (types.ConstantValue, bool) {
+	c, exists := a.constants[stEntry]
+	if !exists {
+		return types.ConstantValue{}, false
+	}
+	if c.done {
+		if stEntry.Value == nil {
+			return types.ConstantValue{}, false
+		}
+		return *stEntry.Value, true
+	}
+	if c.evaluating {
+		a.ReportError(c.decl.Name, "Constant "+c.decl.Name.Spelling+" is defined in terms of itself")
+		return types.ConstantValue{}, false
+	}
+
+	c.evaluating = true
+	value, ok := a.CheckConstantDeclaration(c.decl)
+	c.evaluating = false
+	c.done = true
+	if !ok {
+		return types.ConstantValue{}, false
+	}
+	stEntry.Value = &value
+	return value, true
+}
+
+// CheckConstantDeclaration checks that the value of decl is a
+// constant expression that can be stored in the constant, and
+// returns the value converted to the constant's type.
+func (a *Analyzer) CheckConstantDeclaration(decl *ast.ConstDecl) (types.ConstantValue, bool) {
+	switch {
+	case decl.Type == types.STNone:
+		return types.ConstantValue{}, false
+	case decl.Type != types.STVarInteger && decl.Type != types.STVarFloat && decl.Type != types.STVarBool && decl.Type != types.STVarString && !decl.Type.IsEnum():
+		a.ReportError(decl.Name, "Constant "+decl.Name.Spelling+" cannot be of type "+string(decl.Type))
+		return types.ConstantValue{}, false
+	}
+
+	exprSTType := a.CheckExpression(decl.Value, scope{})
+	if exprSTType == types.STNone {
+		return types.ConstantValue{}, false
+	}
+	if !Assignable(exprSTType, decl.Type) {
+		a.ReportError(decl.Value, "Expression type "+string(exprSTType)+" is not compatible with constant type "+string(decl.Type))
+		return types.ConstantValue{}, false
+	}
+	value, ok := a.Evaluate(decl.Value)
+	if !ok {
+		return types.ConstantValue{}, false
+	}
+	return Convert(value, exprSTType, decl.Type), true
+}
+
+// CheckBound evaluates the bound of an array declaration, which must
+// be a positive integer constant, and records it as the length.  The
+// length stays zero if the bound is wrong.
+func (a *Analyzer) CheckBound(decl *ast.VarDecl) {
+	if !decl.IsArray() {
+		return
+	}
+	stType := a.CheckExpression(decl.Bound, scope{})
+	if stType == types.STNone {
+		return
+	}
+	if stType != types.STVarInteger {
+		a.ReportError(decl.Bound, "Array bound must be an integer, not "+string(stType))
+		return
+	}
+	value, ok := a.Evaluate(decl.Bound)
+	if !ok {
+		return
+	}
+	if value.Int < 1 {
+		a.ReportError(decl.Bound, "Array bound must be positive, not "+FormatConstant(value, stType))
+		return
+	}
+	decl.Length = int(value.Int)
+	decl.Name.Entry.ArraySize = decl.Length
+}
+
+// Evaluate returns the value of expr, which has been type checked.
+// A part of expr that is not constant is reported.
+func (a *Analyzer) Evaluate(expr ast.Expr) (types.ConstantValue, bool) {
+	switch expr := expr.(type) {
+	case *ast.IntLit:
+		return types.ConstantValue{Int: expr.Value}, true
+	case *ast.FloatLit:
+		return types.ConstantValue{Float: expr.Value}, true
+	case *ast.StringLit:
+		return types.ConstantValue{String: expr.Value}, true
+	case *ast.BoolLit:
+		return types.ConstantValue{Int: boolInt(expr.Value)}, true
+	case *ast.Ident:
+		stEntry := expr.Entry
+		switch {
+		case stEntry == nil:
+			return types.ConstantValue{}, false
+		case stEntry.IsEnumValue:
+			return types.ConstantValue{Int: int64(stEntry.EnumOrdinal)}, true
+		case stEntry.IsConstant:
+			return a.ConstantValue(stEntry)
+		}
+		a.ReportError(expr, expr.Spelling+" is not a constant")
+		return types.ConstantValue{}, false
+	case *ast.UnaryExpr:
+		x, ok := a.Evaluate(expr.X)
+		if !ok {
+			return types.ConstantValue{}, false
+		}
+		switch {
+		case expr.Op == types.SubtractionOperator && expr.ExprType() == types.STVarFloat:
+			return types.ConstantValue{Float: -x.Float}, true
+		case expr.Op == types.SubtractionOperator:
+			return types.ConstantValue{Int: -x.Int}, true
+		case expr.ExprType() == types.STVarBool:
+			return types.ConstantValue{Int: 1 - x.Int}, true
+		default:
+			return types.ConstantValue{Int: ^x.Int}, true
+		}
+	case *ast.BinaryExpr:
+		return a.EvaluateBinary(expr)
+	}
+	a.ReportError(expr, Describe(expr)+" is not a constant expression")
+	return types.ConstantValue{}, false
+}
+
+// EvaluateBinary returns the value of a binary expression whose
+// operands are constant.
+func (a *Analyzer) EvaluateBinary(expr *ast.BinaryExpr) (types.ConstantValue, bool) {
+	x, xOk := a.Evaluate(expr.X)
+	y, yOk := a.Evaluate(expr.Y)
+	if !xOk || !yOk {
+		return types.ConstantValue{}, false
+	}
+	xSTType := expr.X.ExprType()
+	ySTType := expr.Y.ExprType()
+
+	switch expr.Op {
+	case types.AndOperator:
+		return types.ConstantValue{Int: x.Int & y.Int}, true
+	case types.OrOperator:
+		return types.ConstantValue{Int: x.Int | y.Int}, true
+	case types.AdditionOperator, types.SubtractionOperator, types.MultiplicationOperator, types.DivisionOperator:
+		if expr.ExprType() == types.STVarFloat {
+			return EvaluateFloat(expr.Op, toFloat(x, xSTType), toFloat(y, ySTType)), true
+		}
+		if expr.Op == types.DivisionOperator && y.Int == 0 {
+			a.ReportError(expr, "Division by zero in constant expression")
+			return types.ConstantValue{}, false
+		}
+		return EvaluateInteger(expr.Op, x.Int, y.Int), true
+	}
+
+	// The operator is a relation
+	var comparison int
+	switch {
+	case xSTType == types.STVarString:
+		comparison = strings.Compare(x.String, y.String)
+	case xSTType == types.STVarFloat || ySTType == types.STVarFloat:
+		comparison = compareFloat(toFloat(x, xSTType), toFloat(y, ySTType))
+	case xSTType == types.STVarBool && ySTType == types.STVarInteger:
+		comparison = compareInt(x.Int, boolInt(y.Int != 0))
+	case xSTType == types.STVarInteger && ySTType == types.STVarBool:
+		comparison = compareInt(boolInt(x.Int != 0), y.Int)
+	default:
+		comparison = compareInt(x.Int, y.Int)
+	}
+	var result bool
+	switch expr.Op {
+	case types.LessThanOperator:
+		result = comparison < 0
+	case types.LessThanEqualOperator:
+		result = comparison <= 0
+	case types.GreaterThanOperator:
+		result = comparison > 0
+	case types.GreaterThanEqualOperator:
+		result = comparison >= 0
+	case types.EqualOperator:
+		result = comparison == 0
+	case types.NotEqualOperator:
+		result = comparison != 0
+	}
+	return types.ConstantValue{Int: boolInt(result)}, true
+}
+
+// EvaluateInteger applies an arithmetic operator to integers.  y is
+// not zero for a division.
+func EvaluateInteger(op types.TokenType, x int64, y int64) types.ConstantValue {
+	switch op {
+	case types.AdditionOperator:
+		return types.ConstantValue{Int: x + y}
+	case types.SubtractionOperator:
+		return types.ConstantValue{Int: x - y}
+	case types.MultiplicationOperator:
+		return types.ConstantValue{Int: x * y}
+	}
+	return types.ConstantValue{Int: x / y}
+}
+
+// EvaluateFloat applies an arithmetic operator to floats.
+func EvaluateFloat(op types.TokenType, x float64, y float64) types.ConstantValue {
+	switch op {
+	case types.AdditionOperator:
+		return types.ConstantValue{Float: x + y}
+	case types.SubtractionOperator:
+		return types.ConstantValue{Float: x - y}
+	case types.MultiplicationOperator:
+		return types.ConstantValue{Float: x * y}
+	}
+	return types.ConstantValue{Float: x / y}
+}
+
+// Convert converts a value between types that Assignable allows.
+// Floats are truncated to integers and integers other than zero
+// become true.
+func Convert(value types.ConstantValue, from types.STType, to types.STType) types.ConstantValue {
+	switch {
+	case from == types.STVarInteger && to == types.STVarFloat:
+		return types.ConstantValue{Float: float64(value.Int)}
+	case from == types.STVarFloat && to == types.STVarInteger:
+		return types.ConstantValue{Int: int64(value.Float)}
+	case from == types.STVarInteger && to == types.STVarBool:
+		return types.ConstantValue{Int: boolInt(value.Int != 0)}
+	}
+	return value
+}
+
+// FormatConstant returns a value of type stType as it would be
+// written in a program.
+func FormatConstant(value types.ConstantValue, stType types.STType) string {
+	switch stType {
+	case types.STVarFloat:
+		return strconv.FormatFloat(value.Float, 'g', -1, 64)
+	case types.STVarString:
+		return strconv.Quote(value.String)
+	case types.STVarBool:
+		return strconv.FormatBool(value.Int != 0)
+	}
+	return strconv.FormatInt(value.Int, 10)
+}
+
+func toFloat(value types.ConstantValue, stType types.STType) float64 {
+	if stType == types.STVarFloat {
+		return value.Float
+	}
+	return float64(value.Int)
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareInt(x int64, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(x float64, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
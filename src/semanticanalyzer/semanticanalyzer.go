@@ -1,582 +1,570 @@
+// The entry point function is SemanticAnalysis
+// SemanticAnalysis creates an Analyzer which walks the abstract
+// syntax tree, after the resolver has bound its names.  Each statement is checked against the type rules
+// and the type of every expression is recorded in the tree for
+// code generation.  All errors are collected and returned together.
+
 package semanticanalyzer
 
 import (
+	"compiler/src/ast"
 	"compiler/src/types"
-	"errors"
-	"log"
+	"strconv"
+	"strings"
 )
 
-var globalSymbolTable = map[string]types.STEntry{}
-var builtinSymbolTable = map[string]types.STEntry{}
-
-func SemanticAnalysis(node *types.ParseNode, parseGlobalSymbolTable map[string]types.STEntry, parseBuiltinSymbolTable map[string]types.STEntry) {
-	globalSymbolTable = parseGlobalSymbolTable
-	builtinSymbolTable = parseGlobalSymbolTable
-
-	err := CheckNode(node, nil, types.STEntry{})
-	if err != nil {
-		log.Fatal(err)
-	}
+// Options selects optional checks.
+type Options struct {
+	// WarnUnusedResult warns about procedure calls made as
+	// statements, whose result is thrown away, except calls to the
+	// builtins that write output.
+	WarnUnusedResult bool
 }
 
-func CheckNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry, stEntry types.STEntry) error {
-	var localST map[string]types.STEntry
-	localST = localSymbolTable
-	if node.Production == types.ProcedureDeclarationProd {
-		localST = node.ProcLocalSymbolTable
-	}
-	var entry types.STEntry
-	entry = stEntry
-	var identifier types.ParseNode
-	if node.Production == types.ProcedureDeclarationProd {
-		header := node.ChildNodes[0]
-		// if header.ChildNodes[0].TerminalToken.TokenType == types.GlobalKeyword {
-		// 	identifier = node.ChildNodes[2]
-		// } else {
-		// 	identifier = node.ChildNodes[1]
-		// }
-		identifier = header.ChildNodes[1]
-		entry = localST[identifier.TerminalToken.StringValue]
-	}
-
-	for _, child := range node.ChildNodes {
-		CheckNode(&child, localST, entry)
-	}
-
-	if node.Production == types.AssignmentStatementProd {
-		// for rule 14
-		return CheckAssignmentStatementNode(node, localST)
-	}
-	if node.Production == types.LoopStatementProd {
-		// for rule 15
-		// check assignment statement
-		return CheckLoopStatementNode(node, localST, entry)
-	}
-	if node.Production == types.IfStatementProd {
-		// for rule 15
-		// check assignment statement
-		return CheckIfStatementNode(node, localST, entry)
-	}
-	if node.Production == types.ReturnStatementProd {
-		// for rule 15
-		// check assignment statement
-		return CheckReturnStatementNode(node, localST, entry)
-	}
+// Analyzer holds the state for checking one program.
+type Analyzer struct {
+	options     Options
+	diagnostics types.DiagnosticList
+	// recordFields holds the fields of each record type declared so far
+	recordFields map[types.STType][]types.STEntry
+	// enumValues holds the values of each enum type declared so far,
+	// as spelled in the declaration
+	enumValues map[types.STType][]string
+	// constants holds every constant declaration in the program
+	constants map[*types.STEntry]*constant
+}
 
-	return nil
+// scope is where a statement is: the procedure it is in, if any, and
+// whether it is inside a loop.
+type scope struct {
+	procedure *ast.ProcDecl
+	inLoop    bool
 }
 
-func CheckProcedureCallNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Procedure call argument types do not match procedure declaration parameter types"
-	identifier := node.ChildNodes[0].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := localSymbolTable[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	stEntryBuiltin, existsBuiltin := builtinSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
-	} else if existsBuiltin {
-		stEntry = stEntryBuiltin
+// NewAnalyzer returns an Analyzer.
+func NewAnalyzer(options Options) *Analyzer {
+	return &Analyzer{
+		options:      options,
+		recordFields: map[types.STType][]types.STEntry{},
+		enumValues:   map[types.STType][]string{},
+		constants:    map[*types.STEntry]*constant{},
 	}
-
-	if node.ChildNodes[2].Production == types.ArgumentListProd {
-		err := CheckArgumentListNode(&node.ChildNodes[3], localSymbolTable, stEntry)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-		// DO SOEMTHING HERE?
-	} else if node.ChildNodes[2].TerminalToken.TokenType == types.CloseRoundBracket {
-		if len(stEntry.ProcedureArgTypes) != 0 {
-			return types.STNone, errors.New(errString)
-		}
-	}
-
-	return stEntry.ProcedureReturnType, nil
 }
 
-func CheckArgumentListNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry, stEntry types.STEntry) error {
-	errString := "Semantic Analysis Error: Procedure call argument types do not match procedure declaration parameter types"
+// SemanticAnalysis checks program, whose names must have been
+// resolved, and returns the warnings found.  If there are errors it
+// also returns a types.DiagnosticList of every error and warning
+// found.
+func SemanticAnalysis(program *ast.Program, options Options) (types.DiagnosticList, error) {
+	analyzer := NewAnalyzer(options)
+	analyzer.CheckProgram(program)
+	return analyzer.diagnostics.Warnings(), analyzer.diagnostics.Err()
+}
 
-	argListSTTypes := []types.STType{}
+func (a *Analyzer) ReportError(node ast.Node, message string) {
+	a.diagnostics.Add(types.NewDiagnostic(node.Span(), "Semantic Analysis Error: "+message))
+}
 
-	for _, child := range node.ChildNodes {
-		argType, err := CheckExpressionNode(&child, localSymbolTable)
-		if err != nil {
-			return errors.New(err.Error())
-		}
-		argListSTTypes = append(argListSTTypes, argType)
-	}
+func (a *Analyzer) ReportWarning(node ast.Node, message string) {
+	a.diagnostics.Add(types.NewWarning(node.Span(), "Semantic Analysis Warning: "+message))
+}
 
-	if len(argListSTTypes) != len(stEntry.ProcedureArgTypes) {
-		return errors.New(errString)
-	}
+func (a *Analyzer) CheckProgram(program *ast.Program) {
+	programScope := scope{}
+	a.FindConstants(program.Decls)
+	a.CheckDeclarations(program.Decls, programScope)
+	a.CheckStatements(program.Body, programScope)
+}
 
-	for i := range argListSTTypes {
-		if argListSTTypes[i] != stEntry.ProcedureArgTypes[i] {
-			return errors.New(errString)
+func (a *Analyzer) CheckDeclarations(decls []ast.Decl, s scope) {
+	for _, decl := range decls {
+		switch decl := decl.(type) {
+		case *ast.VarDecl:
+			a.CheckBound(decl)
+			a.CheckInitializer(decl, s)
+		case *ast.ConstDecl:
+			a.ConstantValue(decl.Name.Entry)
+		case *ast.TypeDecl:
+			stEntry := decl.Name.Entry
+			switch def := decl.Def.(type) {
+			case *ast.RecordDef:
+				for _, field := range def.Fields {
+					a.CheckBound(field)
+					if field.Init != nil {
+						a.ReportError(field.Init, "Field "+field.Name.Spelling+" cannot have an initial value")
+					}
+				}
+				a.recordFields[stEntry.DefinedType] = stEntry.Fields
+			case *ast.EnumDef:
+				values := []string{}
+				for _, value := range def.Values {
+					values = append(values, value.Spelling)
+				}
+				a.enumValues[stEntry.DefinedType] = values
+			}
+		case *ast.ProcDecl:
+			if decl.ReturnType.IsRecord() {
+				a.ReportError(decl.ReturnTypeName, "Procedures cannot return records")
+			}
+			// A nested procedure may use its enclosing procedures'
+			// locals, so it can only be called from inside them
+			if decl.Global && s.procedure != nil {
+				a.ReportError(decl.Name, "Procedure "+decl.Name.Spelling+" is declared inside "+s.procedure.Name.Spelling+" and cannot be global")
+			}
+			for _, param := range decl.Params {
+				a.CheckBound(param)
+				if param.Init != nil {
+					a.ReportError(param.Init, "Parameter "+param.Name.Spelling+" cannot have an initial value")
+				}
+			}
+			procScope := scope{procedure: decl}
+			a.CheckDeclarations(decl.Decls, procScope)
+			a.CheckStatements(decl.Body, procScope)
 		}
 	}
-
-	return nil
 }
 
-func CheckAssignmentStatementNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) error {
-	errString := "Semantic Analysis Error: Expression type is not compatible with destination type"
-
-	destSTType, err := CheckDestinationNode(&node.ChildNodes[0], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
-	}
-
-	exprSTType, err := CheckExpressionNode(&node.ChildNodes[2], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
+// CheckInitializer checks the initial value of a variable against its
+// type.  An array is initialized by an array literal of its length.
+func (a *Analyzer) CheckInitializer(decl *ast.VarDecl, s scope) {
+	if decl.Init == nil {
+		return
+	}
+	// Code generation assigns the value to the variable's name
+	a.CheckExpression(decl.Name, s)
+	list, isList := decl.Init.(*ast.ArrayLit)
+	switch {
+	case decl.IsArray() && !isList:
+		a.ReportError(decl.Init, "Array "+decl.Name.Spelling+" must be initialized with an array literal")
+	case !decl.IsArray() && isList:
+		a.ReportError(decl.Init, decl.Name.Spelling+" is not an array and cannot be initialized with an array literal")
+	case isList:
+		list.SetExprType(decl.EntryType())
+		if decl.Length > 0 && len(list.Elements) != decl.Length {
+			a.ReportError(list, "Array literal has "+strconv.Itoa(len(list.Elements))+" elements but "+decl.Name.Spelling+" has length "+strconv.Itoa(decl.Length))
+		}
+		for _, element := range list.Elements {
+			a.CheckInitialValue(element, decl.Type, s)
+		}
+	default:
+		a.CheckInitialValue(decl.Init, decl.Type, s)
 	}
-
-	if exprSTType != destSTType {
-		if !(exprSTType == types.STVarBool && destSTType == types.STVarInteger) &&
-			!(exprSTType == types.STVarInteger && destSTType == types.STVarBool) &&
-			!(exprSTType == types.STVarInteger && destSTType == types.STVarFloat) &&
-			!(exprSTType == types.STVarFloat && destSTType == types.STVarInteger) {
-			return errors.New(errString)
-		}
-	}
-
-	return nil
 }
 
-func CheckDestinationNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Index expression does not evaluate to an integer"
-
-	identifier := node.ChildNodes[0].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := localSymbolTable[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
+// CheckInitialValue checks that value can be stored in a variable, or
+// array element, of type stType.
+func (a *Analyzer) CheckInitialValue(value ast.Expr, stType types.STType, s scope) {
+	valueSTType := a.CheckExpression(value, s)
+	if valueSTType == types.STNone || stType == types.STNone {
+		return
 	}
-
-	if len(node.ChildNodes) > 1 {
-		exprSTType, err := CheckExpressionNode(&node.ChildNodes[2], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-		if exprSTType != types.STVarInteger {
-			return types.STNone, errors.New(errString)
-		}
-
-		if stEntry.EntryType == types.STVarIntegerArray {
-			return types.STVarInteger, nil
-		} else if stEntry.EntryType == types.STVarFloatArray {
-			return types.STVarFloat, nil
-		} else if stEntry.EntryType == types.STVarStringArray {
-			return types.STVarString, nil
-		} else if stEntry.EntryType == types.STVarBoolArray {
-			return types.STVarBool, nil
-		}
+	if !Assignable(valueSTType, stType) {
+		a.ReportError(value, "Initial value of type "+string(valueSTType)+" is not compatible with variable type "+string(stType))
 	}
-
-	return stEntry.EntryType, nil
 }
 
-func CheckNameNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Index expression does not evaluate to an integer"
-
-	identifier := node.ChildNodes[0].TerminalToken.StringValue
-	var stEntry types.STEntry
-	stEntryLocal, existsLocal := localSymbolTable[identifier]
-	stEntryGlobal, existsGlobal := globalSymbolTable[identifier]
-	if existsLocal {
-		stEntry = stEntryLocal
-	} else if existsGlobal {
-		stEntry = stEntryGlobal
+func (a *Analyzer) CheckStatements(stmts []ast.Stmt, s scope) {
+	for _, stmt := range stmts {
+		a.CheckStatement(stmt, s)
 	}
+}
 
-	if len(node.ChildNodes) > 1 {
-		exprSTType, err := CheckExpressionNode(&node.ChildNodes[2], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-		if exprSTType != types.STVarInteger {
-			return types.STNone, errors.New(errString)
-		}
-
-		if stEntry.EntryType == types.STVarIntegerArray {
-			return types.STVarInteger, nil
-		} else if stEntry.EntryType == types.STVarFloatArray {
-			return types.STVarFloat, nil
-		} else if stEntry.EntryType == types.STVarStringArray {
-			return types.STVarString, nil
-		} else if stEntry.EntryType == types.STVarBoolArray {
-			return types.STVarBool, nil
-		}
+func (a *Analyzer) CheckStatement(stmt ast.Stmt, s scope) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		a.CheckAssignmentStatement(stmt, s)
+	case *ast.IfStmt:
+		a.CheckCondition(stmt.Cond, s, "If expression does not evaluate to a boolean value")
+		a.CheckStatements(stmt.Then, s)
+		a.CheckStatements(stmt.Else, s)
+	case *ast.ForStmt:
+		if stmt.Init != nil {
+			a.CheckAssignmentStatement(stmt.Init, s)
+		}
+		a.CheckCondition(stmt.Cond, s, "Loop expression does not evaluate to a boolean value")
+		a.CheckLoopBody(stmt.Body, s)
+	case *ast.WhileStmt:
+		a.CheckCondition(stmt.Cond, s, "Loop expression does not evaluate to a boolean value")
+		a.CheckLoopBody(stmt.Body, s)
+	case *ast.ExitStmt:
+		if !s.inLoop {
+			a.ReportError(stmt, "exit is only allowed inside a loop")
+		}
+	case *ast.ContinueStmt:
+		if !s.inLoop {
+			a.ReportError(stmt, "continue is only allowed inside a loop")
+		}
+	case *ast.CaseStmt:
+		a.CheckCaseStatement(stmt, s)
+	case *ast.CallStmt:
+		stType := a.CheckExpression(stmt.Call, s)
+		ignorable := stmt.Call.Fun.Entry != nil && stmt.Call.Fun.Entry.IgnorableResult
+		if a.options.WarnUnusedResult && stType != types.STNone && !ignorable {
+			a.ReportWarning(stmt, "The "+string(stType)+" result of "+stmt.Call.Fun.Spelling+" is ignored")
+		}
+	case *ast.ReturnStmt:
+		a.CheckReturnStatement(stmt, s)
 	}
-
-	return stEntry.EntryType, nil
 }
 
-func CheckExpressionNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
+// CheckLoopBody checks the body of a loop, where exit and continue
+// are allowed.
+func (a *Analyzer) CheckLoopBody(body []ast.Stmt, s scope) {
+	s.inLoop = true
+	a.CheckStatements(body, s)
+}
 
-	// hasNot := false
-	aop_index := 0
-	exp_index := 1
-	if node.ChildNodes[0].TerminalToken.TokenType == types.NotOperator {
-		// hasNot = true
-		aop_index = 1
-		exp_index = 2
+// CheckCaseStatement checks that the labels are distinct values of
+// the subject's type and warns about enum values no clause handles.
+func (a *Analyzer) CheckCaseStatement(stmt *ast.CaseStmt, s scope) {
+	subjectSTType := a.CheckExpression(stmt.Subject, s)
+	if subjectSTType != types.STNone && subjectSTType != types.STVarInteger && !subjectSTType.IsEnum() {
+		a.ReportError(stmt.Subject, "Case expression must be an integer or an enum, not "+string(subjectSTType))
+		subjectSTType = types.STNone
+	}
+
+	seen := map[int64]bool{}
+	for _, clause := range stmt.Clauses {
+		for _, label := range clause.Labels {
+			value, ok := a.CheckCaseLabel(label, subjectSTType, s)
+			if !ok {
+				continue
+			}
+			if seen[value] {
+				a.ReportError(label, "Duplicate case label "+Describe(label))
+			}
+			seen[value] = true
+		}
+		a.CheckStatements(clause.Body, s)
 	}
-
-	stType, err := CheckArithOpNode(&node.ChildNodes[aop_index], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+	if stmt.Others != nil {
+		a.CheckStatements(stmt.Others.Body, s)
+		return
 	}
 
-	if len(node.ChildNodes) > 1 {
-		termPrimeSTType, err := CheckExpressionPrimeNode(&node.ChildNodes[exp_index], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
+	if subjectSTType.IsEnum() {
+		missing := []string{}
+		for ordinal, value := range a.enumValues[subjectSTType] {
+			if !seen[int64(ordinal)] {
+				missing = append(missing, value)
+			}
 		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarBool) {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
+		if len(missing) > 0 {
+			a.ReportWarning(stmt, "Case statement does not handle "+strings.Join(missing, ", "))
 		}
 	}
-
-	return stType, nil
 }
 
-func CheckExpressionPrimeNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-
-	// hasNot := false
-	aop_index := 0
-	exp_index := 1
-	if node.ChildNodes[0].TerminalToken.TokenType == types.NotOperator {
-		// hasNot = true
-		aop_index = 1
-		exp_index = 2
+// CheckCaseLabel checks a case label against the subject's type and
+// returns its value.  An enum value's value is its ordinal, and a
+// constant's the value it was declared with.
+func (a *Analyzer) CheckCaseLabel(label ast.Expr, subjectSTType types.STType, s scope) (int64, bool) {
+	labelSTType := a.CheckExpression(label, s)
+	if labelSTType == types.STNone {
+		return 0, false
+	}
+	var value int64
+	switch label := label.(type) {
+	case *ast.IntLit:
+		value = label.Value
+	case *ast.Ident:
+		stEntry := label.Entry
+		switch {
+		case stEntry.IsEnumValue:
+			value = int64(stEntry.EnumOrdinal)
+		case stEntry.IsConstant:
+			constantValue, ok := a.ConstantValue(stEntry)
+			if !ok {
+				return 0, false
+			}
+			value = constantValue.Int
+		default:
+			a.ReportError(label, "Case label "+label.Spelling+" is not an enum value or a constant")
+			return 0, false
+		}
 	}
-
-	stType, err := CheckArithOpNode(&node.ChildNodes[aop_index], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+	if subjectSTType != types.STNone && labelSTType != subjectSTType {
+		a.ReportError(label, "Case label "+Describe(label)+" of type "+string(labelSTType)+" does not match case expression type "+string(subjectSTType))
+		return 0, false
 	}
+	return value, true
+}
 
-	if len(node.ChildNodes) > 1 {
-		termPrimeSTType, err := CheckExpressionPrimeNode(&node.ChildNodes[exp_index], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
+func (a *Analyzer) CheckAssignmentStatement(stmt *ast.AssignStmt, s scope) {
+	if target, ok := stmt.Target.(*ast.Ident); ok && target.Entry != nil {
+		if target.Entry.IsEnumValue {
+			a.ReportError(target, "Cannot assign to the enum value "+target.Spelling)
+			return
 		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarBool) {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
+		if target.Entry.IsConstant {
+			a.ReportError(target, "Cannot assign to the constant "+target.Spelling)
+			return
 		}
 	}
-
-	if stType != types.STVarInteger && stType != types.STVarBool {
-		return types.STNone, errors.New(errString)
+	destSTType := a.CheckExpression(stmt.Target, s)
+	exprSTType := a.CheckExpression(stmt.Value, s)
+	if destSTType == types.STNone || exprSTType == types.STNone {
+		return
 	}
-
-	return stType, nil
-}
-
-func CheckArithOpNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckRelationNode(&node.ChildNodes[0], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+	if IsArrayType(destSTType) {
+		a.ReportError(stmt, "Assignment to a whole array is not supported")
+		return
 	}
-
-	if len(node.ChildNodes) > 1 {
-		termPrimeSTType, err := CheckArithOpPrimeNode(&node.ChildNodes[1], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat) {
-			return stType, nil
-		} else if stType == types.STVarInteger && termPrimeSTType == types.STVarFloat {
-			return termPrimeSTType, nil
-		} else if stType == types.STVarFloat && termPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
-		}
+	if !Assignable(exprSTType, destSTType) {
+		a.ReportError(stmt, "Expression type "+string(exprSTType)+" is not compatible with destination type "+string(destSTType))
 	}
-
-	return stType, nil
 }
 
-func CheckArithOpPrimeNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckFactorNode(&node.ChildNodes[1], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+// CheckCondition checks the condition of an if or loop statement.
+func (a *Analyzer) CheckCondition(cond ast.Expr, s scope, errString string) {
+	stType := a.CheckExpression(cond, s)
+	if stType != types.STNone && stType != types.STVarBool && stType != types.STVarInteger {
+		a.ReportError(cond, errString)
 	}
+}
 
-	if len(node.ChildNodes) > 2 {
-		termPrimeSTType, err := CheckTermPrimeNode(&node.ChildNodes[2], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat) {
-			return stType, nil
-		} else if stType == types.STVarInteger && termPrimeSTType == types.STVarFloat {
-			return termPrimeSTType, nil
-		} else if stType == types.STVarFloat && termPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
-		}
+func (a *Analyzer) CheckReturnStatement(stmt *ast.ReturnStmt, s scope) {
+	stType := a.CheckExpression(stmt.Value, s)
+	if s.procedure == nil || stType == types.STNone {
+		return
 	}
-
-	if stType != types.STVarInteger && stType != types.STVarFloat {
-		return types.STNone, errors.New(errString)
+	if !Assignable(stType, s.procedure.ReturnType) {
+		a.ReportError(stmt.Value, "Returned value of type "+string(stType)+" does not match procedure return type "+string(s.procedure.ReturnType))
 	}
+}
 
-	return stType, nil
+// Assignable reports whether a value of type from may be stored in a
+// destination of type to.  Besides equal types, integers convert to
+// and from both bool and float.
+func Assignable(from types.STType, to types.STType) bool {
+	if from == to {
+		return true
+	}
+	return (from == types.STVarBool && to == types.STVarInteger) ||
+		(from == types.STVarInteger && to == types.STVarBool) ||
+		(from == types.STVarInteger && to == types.STVarFloat) ||
+		(from == types.STVarFloat && to == types.STVarInteger)
 }
 
-func CheckRelationNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckTermNode(&node.ChildNodes[0], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
-	}
+// IsArrayType reports whether stType is one of the array types.
+func IsArrayType(stType types.STType) bool {
+	return stType.IsArray()
+}
 
-	if len(node.ChildNodes) > 1 {
-		relPrimeSTType, err := CheckRelationPrimeNode(&node.ChildNodes[1], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
+// ElementType returns the element type of an array type.
+func ElementType(stType types.STType) types.STType {
+	return stType.ElementType()
+}
 
-		if stType == relPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat || stType == types.STVarBool) {
-			return types.STVarBool, nil
-		} else if stType == types.STVarBool && relPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else if stType == types.STVarInteger && relPrimeSTType == types.STVarBool {
-			return relPrimeSTType, nil
-		} else if stType == types.STVarFloat && relPrimeSTType == types.STVarFloat {
-			return types.STVarBool, nil
-		} else if stType == types.STVarString && relPrimeSTType == types.STVarString {
-			if node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType == types.EqualOperator || node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType == types.NotEqualOperator {
-				return types.STVarBool, nil
-			} else {
-				return types.STNone, errors.New(errString)
-			}
-		} else {
-			return types.STNone, errors.New(errString)
-		}
-	}
+// CheckExpression returns the type of expr and records it in the
+// tree.  STNone means an error has already been reported.
+func (a *Analyzer) CheckExpression(expr ast.Expr, s scope) types.STType {
+	stType := a.expressionType(expr, s)
+	expr.SetExprType(stType)
+	return stType
+}
 
-	return stType, nil
+func (a *Analyzer) expressionType(expr ast.Expr, s scope) types.STType {
+	switch expr := expr.(type) {
+	case *ast.IntLit:
+		return types.STVarInteger
+	case *ast.FloatLit:
+		return types.STVarFloat
+	case *ast.StringLit:
+		return types.STVarString
+	case *ast.BoolLit:
+		return types.STVarBool
+	case *ast.Ident:
+		return a.CheckIdentifier(expr, s)
+	case *ast.IndexExpr:
+		return a.CheckIndexExpression(expr, s)
+	case *ast.SelectorExpr:
+		return a.CheckSelectorExpression(expr, s)
+	case *ast.CallExpr:
+		return a.CheckProcedureCall(expr, s)
+	case *ast.UnaryExpr:
+		return a.CheckUnaryExpression(expr, s)
+	case *ast.BinaryExpr:
+		return a.CheckBinaryExpression(expr, s)
+	}
+	return types.STNone
 }
 
-func CheckRelationPrimeNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckTermNode(&node.ChildNodes[1], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+func (a *Analyzer) CheckIdentifier(ident *ast.Ident, s scope) types.STType {
+	// An unresolved name has already been reported by the resolver
+	stEntry := ident.Entry
+	if stEntry == nil {
+		return types.STNone
 	}
-
-	if len(node.ChildNodes) > 2 {
-		relPrimeSTType, err := CheckRelationPrimeNode(&node.ChildNodes[2], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-
-		if stType == relPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat || stType == types.STVarBool) {
-			return stType, nil
-		} else if stType == types.STVarBool && relPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else if stType == types.STVarInteger && relPrimeSTType == types.STVarBool {
-			return relPrimeSTType, nil
-		} else if stType == types.STVarFloat && relPrimeSTType == types.STVarFloat {
-			return stType, nil
-		} else if stType == types.STVarString && relPrimeSTType == types.STVarString {
-			if node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType == types.EqualOperator || node.ChildNodes[1].ChildNodes[0].TerminalToken.TokenType == types.NotEqualOperator {
-				return types.STVarBool, nil
-			} else {
-				return types.STNone, errors.New(errString)
-			}
-		} else {
-			return types.STNone, errors.New(errString)
-		}
+	if stEntry.EntryType == types.STProcedure {
+		a.ReportError(ident, "procedure "+ident.Spelling+" is used as a variable")
+		return types.STNone
 	}
-
-	if stType != types.STVarInteger && stType != types.STVarFloat && stType != types.STVarBool && stType != types.STVarString {
-		return types.STNone, errors.New(errString)
+	if stEntry.EntryType == types.STTypeDefinition {
+		a.ReportError(ident, "type "+ident.Spelling+" is used as a value")
+		return types.STNone
 	}
-
-	return stType, nil
+	return stEntry.EntryType
 }
 
-func CheckTermNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckFactorNode(&node.ChildNodes[0], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+func (a *Analyzer) CheckIndexExpression(expr *ast.IndexExpr, s scope) types.STType {
+	arraySTType := a.CheckExpression(expr.X, s)
+	indexSTType := a.CheckExpression(expr.Index, s)
+	if arraySTType == types.STNone || indexSTType == types.STNone {
+		return types.STNone
 	}
-
-	if len(node.ChildNodes) > 1 {
-		termPrimeSTType, err := CheckTermPrimeNode(&node.ChildNodes[1], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat) {
-			return stType, nil
-		} else if stType == types.STVarInteger && termPrimeSTType == types.STVarFloat {
-			return termPrimeSTType, nil
-		} else if stType == types.STVarFloat && termPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
-		}
+	if !IsArrayType(arraySTType) {
+		a.ReportError(expr.X, Describe(expr.X)+" is not an array")
+		return types.STNone
 	}
-
-	return stType, nil
+	if indexSTType != types.STVarInteger {
+		a.ReportError(expr.Index, "Index expression does not evaluate to an integer")
+		return types.STNone
+	}
+	return ElementType(arraySTType)
 }
 
-func CheckTermPrimeNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Incompatible types"
-	stType, err := CheckFactorNode(&node.ChildNodes[1], localSymbolTable)
-	if err != nil {
-		return types.STNone, errors.New(err.Error())
+func (a *Analyzer) CheckSelectorExpression(expr *ast.SelectorExpr, s scope) types.STType {
+	recordSTType := a.CheckExpression(expr.X, s)
+	if recordSTType == types.STNone {
+		return types.STNone
 	}
-
-	if len(node.ChildNodes) > 2 {
-		termPrimeSTType, err := CheckTermPrimeNode(&node.ChildNodes[2], localSymbolTable)
-		if err != nil {
-			return types.STNone, errors.New(err.Error())
-		}
-
-		if stType == termPrimeSTType && (stType == types.STVarInteger || stType == types.STVarFloat) {
-			return stType, nil
-		} else if stType == types.STVarInteger && termPrimeSTType == types.STVarFloat {
-			return termPrimeSTType, nil
-		} else if stType == types.STVarFloat && termPrimeSTType == types.STVarInteger {
-			return stType, nil
-		} else {
-			return types.STNone, errors.New(errString)
-		}
+	if !recordSTType.IsRecord() {
+		a.ReportError(expr.X, Describe(expr.X)+" is not a record")
+		return types.STNone
 	}
-
-	if stType != types.STVarInteger && stType != types.STVarFloat {
-		return types.STNone, errors.New(errString)
-	}
-
-	return stType, nil
-}
-
-func CheckFactorNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry) (types.STType, error) {
-	errString := "Semantic Analysis Error: Unknown factor type"
-
-	if node.ChildNodes[0].TerminalToken.TokenType == types.SubtractionOperator {
-		if node.ChildNodes[1].Production == types.NameProd {
-			return CheckNameNode(&node.ChildNodes[1], localSymbolTable)
-		} else if node.ChildNodes[1].Production == types.NumberProd {
-			if node.ChildNodes[1].TerminalToken.TokenType == types.FloatToken {
-				return types.STVarFloat, nil
-			} else if node.ChildNodes[1].TerminalToken.TokenType == types.IntegerToken {
-				return types.STVarInteger, nil
-			}
-		}
-	} else if node.ChildNodes[0].TerminalToken.TokenType == types.OpenRoundBracket {
-		return CheckExpressionNode(&node.ChildNodes[1], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.ProcedureCallProd {
-		return CheckProcedureCallNode(&node.ChildNodes[0], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.NameProd {
-		return CheckNameNode(&node.ChildNodes[0], localSymbolTable)
-	} else if node.ChildNodes[0].Production == types.NumberProd {
-		if node.ChildNodes[0].TerminalToken.TokenType == types.FloatToken {
-			return types.STVarFloat, nil
-		} else if node.ChildNodes[0].TerminalToken.TokenType == types.IntegerToken {
-			return types.STVarInteger, nil
+	for _, field := range a.recordFields[recordSTType] {
+		if field.Identifier == expr.Field.Name {
+			return field.EntryType
 		}
-	} else if node.ChildNodes[0].Production == types.StringProd {
-		return types.STVarString, nil
-	} else if node.ChildNodes[0].TerminalToken.TokenType == types.TrueKeyword || node.ChildNodes[0].TerminalToken.TokenType == types.FalseKeyword {
-		return types.STVarBool, nil
 	}
+	a.ReportError(expr.Field, Describe(expr.X)+" has no field "+expr.Field.Spelling)
+	return types.STNone
+}
 
-	return types.STNone, errors.New(errString)
+// Describe returns the source form of a name for a diagnostic.
+func Describe(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Spelling
+	case *ast.IntLit:
+		return strconv.FormatInt(expr.Value, 10)
+	case *ast.IndexExpr:
+		return Describe(expr.X) + "[...]"
+	case *ast.SelectorExpr:
+		return Describe(expr.X) + "." + expr.Field.Spelling
+	}
+	return "expression"
 }
 
-func CheckLoopStatementNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry, stEntry types.STEntry) error {
-	errString := "Semantic Analysis Error: Loop expression does not evaluate to a boolean value"
+func (a *Analyzer) CheckProcedureCall(call *ast.CallExpr, s scope) types.STType {
+	errString := "Procedure call argument types do not match procedure declaration parameter types"
 
-	err := CheckAssignmentStatementNode(&node.ChildNodes[2], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
+	stEntry := call.Fun.Entry
+	if stEntry == nil {
+		return types.STNone
+	}
+	if stEntry.EntryType != types.STProcedure {
+		a.ReportError(call.Fun, call.Fun.Spelling+" is not a procedure")
+		return types.STNone
 	}
 
-	stType, err := CheckExpressionNode(&node.ChildNodes[4], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
+	argListSTTypes := []types.STType{}
+	for _, arg := range call.Args {
+		argListSTTypes = append(argListSTTypes, a.CheckExpression(arg, s))
 	}
-	if stType != types.STVarBool || stType != types.STVarInteger {
-		return errors.New(errString)
+	if len(argListSTTypes) != len(stEntry.ProcedureArgTypes) {
+		a.ReportError(call, errString)
+		return stEntry.ProcedureReturnType
 	}
-
-	for _, child := range node.ChildNodes[6:] {
-		if child.TerminalToken.TokenType == types.EndKeyword {
-			break
+	for i, arg := range stEntry.ProcedureArgTypes {
+		if argListSTTypes[i] != types.STNone && argListSTTypes[i] != arg.Type {
+			a.ReportError(call.Args[i], errString)
 		}
-		err = CheckNode(&child, localSymbolTable, stEntry)
-		if err != nil {
-			return errors.New(err.Error())
+		if arg.Mode.IsReference() && !a.IsVariable(call.Args[i], s) {
+			a.ReportError(call.Args[i], "Argument "+strconv.Itoa(i+1)+" of "+call.Fun.Spelling+" is passed "+string(arg.Mode)+" and must be a variable")
 		}
 	}
 
-	return nil
+	return stEntry.ProcedureReturnType
 }
 
-func CheckIfStatementNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry, stEntry types.STEntry) error {
-	errString := "Semantic Analysis Error: If expression does not evaluate to a boolean value"
+// IsVariable reports whether expr names a variable, an element of
+// one or a field of one, so it can be passed by reference.
+func (a *Analyzer) IsVariable(expr ast.Expr, s scope) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		stEntry := expr.Entry
+		return stEntry != nil && !stEntry.IsEnumValue && !stEntry.IsConstant && stEntry.EntryType != types.STProcedure && stEntry.EntryType != types.STTypeDefinition
+	case *ast.IndexExpr:
+		return a.IsVariable(expr.X, s)
+	case *ast.SelectorExpr:
+		return a.IsVariable(expr.X, s)
+	}
+	return false
+}
 
-	stType, err := CheckExpressionNode(&node.ChildNodes[2], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
+// CheckUnaryExpression allows minus on integers and floats, and not
+// on bools and on integers, where it inverts every bit.
+func (a *Analyzer) CheckUnaryExpression(expr *ast.UnaryExpr, s scope) types.STType {
+	stType := a.CheckExpression(expr.X, s)
+	if stType == types.STNone {
+		return types.STNone
 	}
-	if stType != types.STVarBool || stType != types.STVarInteger {
-		return errors.New(errString)
+	if expr.Op == types.NotOperator && (stType == types.STVarInteger || stType == types.STVarBool) {
+		return stType
 	}
+	if expr.Op == types.SubtractionOperator && (stType == types.STVarInteger || stType == types.STVarFloat) {
+		return stType
+	}
+	a.ReportError(expr, "Incompatible type for "+string(expr.Op)+": "+string(stType))
+	return types.STNone
+}
 
-	for _, child := range node.ChildNodes[5:] {
-		if child.TerminalToken.TokenType == types.EndKeyword {
-			break
+// CheckBinaryExpression applies the type rules of the three operator
+// groups: & and | take two integers or two bools; arithmetic takes
+// integers and floats, giving a float if either is a float;
+// relations compare like types, bools with integers, and strings
+// and enums of one type for equality only.
+func (a *Analyzer) CheckBinaryExpression(expr *ast.BinaryExpr, s scope) types.STType {
+	xSTType := a.CheckExpression(expr.X, s)
+	ySTType := a.CheckExpression(expr.Y, s)
+	if xSTType == types.STNone || ySTType == types.STNone {
+		return types.STNone
+	}
+
+	switch expr.Op {
+	case types.AndOperator, types.OrOperator:
+		if xSTType == ySTType && (xSTType == types.STVarInteger || xSTType == types.STVarBool) {
+			return xSTType
+		}
+	case types.AdditionOperator, types.SubtractionOperator, types.MultiplicationOperator, types.DivisionOperator:
+		if IsNumericType(xSTType) && IsNumericType(ySTType) {
+			if xSTType == types.STVarFloat || ySTType == types.STVarFloat {
+				return types.STVarFloat
+			}
+			return types.STVarInteger
+		}
+	case types.EqualOperator, types.NotEqualOperator:
+		if xSTType == ySTType && (xSTType == types.STVarString || xSTType.IsEnum()) {
+			return types.STVarBool
 		}
-		err = CheckNode(&child, localSymbolTable, stEntry)
-		if err != nil {
-			return errors.New(err.Error())
+		fallthrough
+	default:
+		if xSTType == ySTType && (xSTType == types.STVarInteger || xSTType == types.STVarFloat || xSTType == types.STVarBool) {
+			return types.STVarBool
+		}
+		if (xSTType == types.STVarBool && ySTType == types.STVarInteger) ||
+			(xSTType == types.STVarInteger && ySTType == types.STVarBool) {
+			return types.STVarBool
 		}
 	}
 
-	return nil
+	a.ReportError(expr, "Incompatible types for "+string(expr.Op)+": "+string(xSTType)+" and "+string(ySTType))
+	return types.STNone
 }
 
-func CheckReturnStatementNode(node *types.ParseNode, localSymbolTable map[string]types.STEntry, stEntry types.STEntry) error {
-	errString := "Semantic Analysis Error: Returned value does not match procedure declaration return type"
-
-	stType, err := CheckExpressionNode(&node.ChildNodes[1], localSymbolTable)
-	if err != nil {
-		return errors.New(err.Error())
-	}
-
-	if stType != stEntry.EntryType {
-		return errors.New(errString)
-	}
-
-	return nil
+// IsNumericType reports whether stType is integer or float.
+func IsNumericType(stType types.STType) bool {
+	return stType == types.STVarInteger || stType == types.STVarFloat
 }
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
 	"strconv"
 	"strings"
 )
@@ -22,6 +24,8 @@ const (
 	ProcedureKeyword TokenType = "procedure"
 	// VariableKeyword ...
 	VariableKeyword TokenType = "variable"
+	// ConstantKeyword ...
+	ConstantKeyword TokenType = "constant"
 	// TypeKeyword ...
 	TypeKeyword TokenType = "type"
 	// IntegerKeyword ...
@@ -34,6 +38,8 @@ const (
 	BoolKeyword TokenType = "bool"
 	// EnumKeyword ...
 	EnumKeyword TokenType = "enum"
+	// RecordKeyword ...
+	RecordKeyword TokenType = "record"
 	// IfKeyword ...
 	IfKeyword TokenType = "if"
 	// ThenKeyword ...
@@ -44,6 +50,18 @@ const (
 	ForKeyword TokenType = "for"
 	// ReturnKeyword ...
 	ReturnKeyword TokenType = "return"
+	// WhileKeyword ...
+	WhileKeyword TokenType = "while"
+	// ExitKeyword ...
+	ExitKeyword TokenType = "exit"
+	// ContinueKeyword ...
+	ContinueKeyword TokenType = "continue"
+	// CaseKeyword ...
+	CaseKeyword TokenType = "case"
+	// WhenKeyword ...
+	WhenKeyword TokenType = "when"
+	// OthersKeyword ...
+	OthersKeyword TokenType = "others"
 	// TrueKeyword ...
 	TrueKeyword TokenType = "true"
 	// FalseKeyword ...
@@ -108,6 +126,9 @@ const (
 	FloatToken TokenType = "FloatToken"
 	// StringToken ...
 	StringToken TokenType = "StringToken"
+	// EOFToken ends every scanned token list.  Its span is the empty
+	// range at the end of the source.
+	EOFToken TokenType = "EOF"
 )
 
 var KeywordTokenTypeMap = map[string]TokenType{
@@ -118,17 +139,25 @@ var KeywordTokenTypeMap = map[string]TokenType{
 	"global":    GlobalKeyword,
 	"procedure": ProcedureKeyword,
 	"variable":  VariableKeyword,
+	"constant":  ConstantKeyword,
 	"type":      TypeKeyword,
 	"integer":   IntegerKeyword,
 	"float":     FloatKeyword,
 	"string":    StringKeyword,
 	"bool":      BoolKeyword,
 	"enum":      EnumKeyword,
+	"record":    RecordKeyword,
 	"if":        IfKeyword,
 	"then":      ThenKeyword,
 	"else":      ElseKeyword,
 	"for":       ForKeyword,
 	"return":    ReturnKeyword,
+	"while":     WhileKeyword,
+	"exit":      ExitKeyword,
+	"continue":  ContinueKeyword,
+	"case":      CaseKeyword,
+	"when":      WhenKeyword,
+	"others":    OthersKeyword,
 	"true":      TrueKeyword,
 	"false":     FalseKeyword,
 	"not":       NotOperator,
@@ -165,82 +194,235 @@ type ScanCode int
 const (
 	// TokenScanCode ...
 	TokenScanCode ScanCode = 0
-	// StopWithTokenScanCode ...
-	StopWithTokenScanCode ScanCode = 1
-	// NewlineScanCode ...
-	NewlineScanCode ScanCode = 2
-	// AtNextByteScanCode ...
-	AtNextByteScanCode ScanCode = 3
 	// ErrorScanCode ...
 	ErrorScanCode ScanCode = 4
-	// LineCommentScanCode ...
-	LineCommentScanCode ScanCode = 5
-	// BlockCommentOpenScanCode ...
-	BlockCommentOpenScanCode ScanCode = 6
-	// BlockCommentCloseScanCode ...
-	BlockCommentCloseScanCode ScanCode = 7
-	// StopNoTokenScanCode ...
-	StopNoTokenScanCode ScanCode = 8
 )
 
+// Position is a location in a source file.  Line and Column
+// are 1-based, Column counts runes and Offset counts bytes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Span is the range of source text between Start (inclusive)
+// and End (exclusive).
+type Span struct {
+	FileName string   `json:"file"`
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+}
+
+// IsValid reports whether the span points into a source file.
+func (span Span) IsValid() bool {
+	return span.Start.Line > 0
+}
+
+// String formats the start of the span as file:line:col.
+func (span Span) String() string {
+	if !span.IsValid() {
+		return span.FileName
+	}
+	return span.FileName + ":" + strconv.Itoa(span.Start.Line) + ":" + strconv.Itoa(span.Start.Column)
+}
+
+// Join returns the smallest span covering both span and other.
+func (span Span) Join(other Span) Span {
+	if !span.IsValid() {
+		return other
+	}
+	if !other.IsValid() {
+		return span
+	}
+	if other.Start.Offset < span.Start.Offset {
+		span.Start = other.Start
+	}
+	if other.End.Offset > span.End.Offset {
+		span.End = other.End
+	}
+	return span
+}
+
+// Severity says whether a diagnostic stops the compile.
+type Severity int
+
+const (
+	// SeverityError ...
+	SeverityError Severity = iota
+	// SeverityWarning is reported without stopping the compile.
+	SeverityWarning
+)
+
+// Diagnostic is an error or warning tied to a location in the source.
+type Diagnostic struct {
+	Span     Span
+	Message  string
+	Severity Severity
+}
+
+func (diagnostic Diagnostic) Error() string {
+	return diagnostic.Span.String() + ": " + diagnostic.Message
+}
+
+// NewDiagnostic returns an error reported at span.
+func NewDiagnostic(span Span, message string) error {
+	return Diagnostic{Span: span, Message: message}
+}
+
+// NewWarning returns a warning reported at span.
+func NewWarning(span Span, message string) error {
+	return Diagnostic{Span: span, Message: message, Severity: SeverityWarning}
+}
+
+// DiagnosticList collects the diagnostics produced by a pass so
+// they can be reported together.
+type DiagnosticList []Diagnostic
+
+// Add appends err, flattening nested lists.  Errors that are not
+// diagnostics are recorded without a location.
+func (list *DiagnosticList) Add(err error) {
+	switch diagnostic := err.(type) {
+	case nil:
+	case Diagnostic:
+		*list = append(*list, diagnostic)
+	case DiagnosticList:
+		*list = append(*list, diagnostic...)
+	default:
+		*list = append(*list, Diagnostic{Message: err.Error()})
+	}
+}
+
+// Err returns the list as an error, or nil if it holds no errors.
+// Warnings alone are not an error.
+func (list DiagnosticList) Err() error {
+	for _, diagnostic := range list {
+		if diagnostic.Severity == SeverityError {
+			return list
+		}
+	}
+	return nil
+}
+
+// Warnings returns the warnings in the list.
+func (list DiagnosticList) Warnings() DiagnosticList {
+	var warnings DiagnosticList
+	for _, diagnostic := range list {
+		if diagnostic.Severity == SeverityWarning {
+			warnings = append(warnings, diagnostic)
+		}
+	}
+	return warnings
+}
+
+func (list DiagnosticList) Error() string {
+	lines := make([]string, len(list))
+	for i, diagnostic := range list {
+		lines[i] = diagnostic.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type TriviaKind string
+
+const (
+	// WhitespaceTrivia is a run of spaces, tabs and carriage returns.
+	WhitespaceTrivia TriviaKind = "whitespace"
+	// NewlineTrivia is a single line feed.
+	NewlineTrivia TriviaKind = "newline"
+	// LineCommentTrivia is a // comment, not including its newline.
+	LineCommentTrivia TriviaKind = "line_comment"
+	// BlockCommentTrivia is a /* */ comment, including nested ones.
+	BlockCommentTrivia TriviaKind = "block_comment"
+)
+
+// Trivia is source text between tokens that does not affect
+// the program, such as whitespace and comments.
+type Trivia struct {
+	Kind TriviaKind `json:"kind"`
+	Text string     `json:"text"`
+	Span Span       `json:"span"`
+}
+
+// Token is a lexeme with its value.  Lexeme is the exact source
+// text.  LeadingTrivia and TrailingTrivia are only filled in
+// when the scanner is asked to keep trivia; trailing trivia runs
+// up to and including the end of the token's line.
 type Token struct {
-	LineNumber  int
-	TokenType   TokenType
-	StringValue string
-	IntValue    int64
-	FloatValue  float64
+	TokenType      TokenType
+	StringValue    string
+	IntValue       int64
+	FloatValue     float64
+	Span           Span
+	Lexeme         string
+	LeadingTrivia  []Trivia
+	TrailingTrivia []Trivia
+}
+
+// MarshalJSON encodes the token with only the value that applies
+// to its type.
+func (token Token) MarshalJSON() ([]byte, error) {
+	var value interface{}
+	switch token.TokenType {
+	case IdentifierToken, StringToken:
+		value = token.StringValue
+	case IntegerToken:
+		value = token.IntValue
+	case FloatToken:
+		value = token.FloatValue
+	}
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(struct {
+		TokenType      TokenType   `json:"type"`
+		Lexeme         string      `json:"lexeme"`
+		Value          interface{} `json:"value,omitempty"`
+		Span           Span        `json:"span"`
+		LeadingTrivia  []Trivia    `json:"leading_trivia,omitempty"`
+		TrailingTrivia []Trivia    `json:"trailing_trivia,omitempty"`
+	}{token.TokenType, token.Lexeme, value, token.Span, token.LeadingTrivia, token.TrailingTrivia})
+	return bytes.TrimRight(encoded.Bytes(), "\n"), err
 }
 
-func BuildToken(lineNumber int, word string, scanCode ScanCode) (Token, ScanCode) {
-	token := Token{lineNumber, "", word, -1, -1}
+func BuildToken(span Span, word string, scanCode ScanCode) (Token, ScanCode) {
+	token := Token{TokenType: "", StringValue: word, IntValue: -1, FloatValue: -1, Span: span}
 	return token, scanCode
 }
 
-func BuildTokenFromAlphaNumeric(lineNumber int, word string, scanCode ScanCode) (Token, ScanCode) {
+func BuildTokenFromAlphaNumeric(span Span, word string, scanCode ScanCode) (Token, ScanCode) {
 	var token Token
 	tokenType, exists := KeywordTokenTypeMap[word]
 	if exists {
-		token = Token{lineNumber, tokenType, "", -1, -1}
+		token = Token{TokenType: tokenType, StringValue: "", IntValue: -1, FloatValue: -1, Span: span}
 	} else {
-		token = Token{lineNumber, IdentifierToken, word, -1, -1}
+		token = Token{TokenType: IdentifierToken, StringValue: word, IntValue: -1, FloatValue: -1, Span: span}
 	}
 	return token, scanCode
 }
 
-func BuildTokenFromNumeric(lineNumber int, word string, scanCode ScanCode) (Token, ScanCode) {
-	var token Token
-	if strings.Index(word, ".") == -1 {
-		intValue, err := strconv.ParseInt(word, 10, 64)
-		if err != nil {
-			token = Token{lineNumber, "", "", -1, -1}
-			scanCode = ErrorScanCode
-		} else {
-			token = Token{lineNumber, IntegerToken, "", intValue, -1}
-		}
-	} else {
-		float64Value, err := strconv.ParseFloat(word, 64)
-		if err != nil {
-			token = Token{lineNumber, "", "", -1, -1}
-			scanCode = ErrorScanCode
-		} else {
-			token = Token{lineNumber, FloatToken, "", -1, float64Value}
-		}
-	}
+func BuildTokenFromInteger(span Span, value int64, scanCode ScanCode) (Token, ScanCode) {
+	token := Token{TokenType: IntegerToken, StringValue: "", IntValue: value, FloatValue: -1, Span: span}
 	return token, scanCode
 }
 
-func BuildTokenFromString(lineNumber int, word string, scanCode ScanCode) (Token, ScanCode) {
-	token := Token{lineNumber, StringToken, word, -1, -1}
+func BuildTokenFromFloat(span Span, value float64, scanCode ScanCode) (Token, ScanCode) {
+	token := Token{TokenType: FloatToken, StringValue: "", IntValue: -1, FloatValue: value, Span: span}
 	return token, scanCode
 }
 
-func BuildTokenFromSymbol(lineNumber int, word string, scanCode ScanCode) (Token, ScanCode) {
+func BuildTokenFromString(span Span, word string, scanCode ScanCode) (Token, ScanCode) {
+	token := Token{TokenType: StringToken, StringValue: word, IntValue: -1, FloatValue: -1, Span: span}
+	return token, scanCode
+}
+
+func BuildTokenFromSymbol(span Span, word string, scanCode ScanCode) (Token, ScanCode) {
 	var token Token
 	tokenType, exists := SymbolTokenTypeMap[word]
 	if exists {
-		token = Token{lineNumber, tokenType, "", -1, -1}
+		token = Token{TokenType: tokenType, StringValue: "", IntValue: -1, FloatValue: -1, Span: span}
 	} else {
-		token = Token{lineNumber, "", "", -1, -1}
+		token = Token{TokenType: "", StringValue: "", IntValue: -1, FloatValue: -1, Span: span}
 		scanCode = ErrorScanCode
 	}
 	return token, scanCode
@@ -265,13 +447,19 @@ const (
 	ProcedureDeclarationProd ProductionType = "<procedure_declaration>"
 	// VariableDeclarationProd ...
 	VariableDeclarationProd ProductionType = "<variable_declaration>"
-	// // TypeDeclarationProd ...
-	// TypeDeclarationProd ProductionType = ""
+	// TypeDeclarationProd ...
+	TypeDeclarationProd ProductionType = "<type_declaration>"
+	// ConstantDeclarationProd ...
+	ConstantDeclarationProd ProductionType = "<constant_declaration>"
+	// EnumTypeProd ...
+	EnumTypeProd ProductionType = "<enum_type>"
+	// RecordTypeProd ...
+	RecordTypeProd ProductionType = "<record_type>"
 	// ProcedureHeaderProd ...
 	ProcedureHeaderProd ProductionType = "<procedure_header>"
 	// ProcedureBodyProd ...
 	ProcedureBodyProd ProductionType = "<procedure_body>"
-	// TypeMarkProd ...
+	// TypeMarkProd is a type mark naming a declared type
 	TypeMarkProd ProductionType = "<type_mark>"
 	// ParamaterListProd ...
 	ParamaterListProd ProductionType = "<parameter_list>"
@@ -279,6 +467,11 @@ const (
 	ParamaterProd ProductionType = "<parameter>"
 	// BoundProd ...
 	BoundProd ProductionType = "<bound>"
+	// InitializerProd is the initial value of a variable, an
+	// expression or an array literal
+	InitializerProd ProductionType = "<initializer>"
+	// ArrayLiteralProd is a bracketed list of expressions
+	ArrayLiteralProd ProductionType = "<array_literal>"
 	// NumberProd ...
 	NumberProd ProductionType = "<number>"
 	// AssignmentStatementProd ..
@@ -289,30 +482,32 @@ const (
 	LoopStatementProd ProductionType = "<loop_statement>"
 	// ReturnStatementProd ...
 	ReturnStatementProd ProductionType = "<return_statement>"
+	// WhileStatementProd ...
+	WhileStatementProd ProductionType = "<while_statement>"
+	// ExitStatementProd ...
+	ExitStatementProd ProductionType = "<exit_statement>"
+	// ContinueStatementProd ...
+	ContinueStatementProd ProductionType = "<continue_statement>"
+	// CaseStatementProd ...
+	CaseStatementProd ProductionType = "<case_statement>"
+	// CaseAlternativeProd is a when clause with its labels and statements
+	CaseAlternativeProd ProductionType = "<case_alternative>"
+	// CaseLabelProd is an integer literal, possibly negated, or an enum value
+	CaseLabelProd ProductionType = "<case_label>"
 	// ProcedureCallProd ...
 	ProcedureCallProd ProductionType = "<procedure_call>"
 	// DestinationProd ...
 	DestinationProd ProductionType = "<destination>"
 	// ExpressionProd ...
 	ExpressionProd ProductionType = "<expression>"
-	// ExpressionPrimeProd ...
-	ExpressionPrimeProd ProductionType = "<expression>"
-	// ArithOpProd ...
-	ArithOpProd ProductionType = "<arithOp>"
-	// ArithOpPrimeProd ...
-	ArithOpPrimeProd ProductionType = "<arithOpPrime>"
-	// RelationProd ...
-	RelationProd ProductionType = "<relation>"
-	// RelationPrimeProd ...
-	RelationPrimeProd ProductionType = "<relationPrime>"
-	// TermProd ...
-	TermProd ProductionType = "<term>"
-	// TermPrimeProd ...
-	TermPrimeProd ProductionType = "<termPrime>"
+	// BinaryOperationProd is a left operand, an operator and a right operand
+	BinaryOperationProd ProductionType = "<binary_operation>"
 	// FactorProd ...
 	FactorProd ProductionType = "<factor>"
 	// NameProd ...
 	NameProd ProductionType = "<name>"
+	// FieldProd is a field selected from a record in a name or destination
+	FieldProd ProductionType = "<field>"
 	// AgurmentList ...
 	ArgumentListProd ProductionType = "<argument_list>"
 	// StringProd ...
@@ -321,13 +516,31 @@ const (
 	KeywordTerminal ProductionType = "<KeywordTerminal>"
 	// SymbolTerminal ...
 	SymbolTerminal ProductionType = "<SymbolTerminal>"
+	// ErrorProd stands in for a declaration or statement that could
+	// not be parsed.  Its children are the tokens that were skipped.
+	ErrorProd ProductionType = "<error>"
+	// SkippedTerminal is a token skipped while recovering from a
+	// syntax error.
+	SkippedTerminal ProductionType = "<SkippedTerminal>"
 )
 
 type ParseNode struct {
-	Production           ProductionType
-	TerminalToken        Token
-	ChildNodes           []ParseNode
-	ProcLocalSymbolTable map[string]STEntry
+	Production    ProductionType
+	TerminalToken Token
+	ChildNodes    []ParseNode
+}
+
+// Span returns the source range covered by the node.  Terminal
+// nodes use their token's span, other nodes join their children.
+func (node *ParseNode) Span() Span {
+	if node.TerminalToken.Span.IsValid() {
+		return node.TerminalToken.Span
+	}
+	var span Span
+	for i := range node.ChildNodes {
+		span = span.Join(node.ChildNodes[i].Span())
+	}
+	return span
 }
 
 type STType string
@@ -351,15 +564,116 @@ const (
 	STVarBoolArray STType = "bool_array"
 	// STProcedure ...
 	STProcedure STType = "procedure"
-	STNone      STType = "none"
+	// STTypeDefinition is the entry type of a declared type name
+	STTypeDefinition STType = "type"
+	STNone           STType = "none"
+)
+
+// Declared types form a family of STTypes made from the kind of
+// type and its name, such as "enum color".  Arrays of them add the
+// "_array" suffix like the builtin types.
+const enumPrefix = "enum "
+const recordPrefix = "record "
+
+// EnumType returns the STType of the enum type declared as name.
+func EnumType(name string) STType {
+	return STType(enumPrefix + name)
+}
+
+// RecordType returns the STType of the record type declared as name.
+func RecordType(name string) STType {
+	return STType(recordPrefix + name)
+}
+
+// IsEnum reports whether stType is an enum type.
+func (stType STType) IsEnum() bool {
+	return strings.HasPrefix(string(stType), enumPrefix) && !stType.IsArray()
+}
+
+// IsRecord reports whether stType is a record type.
+func (stType STType) IsRecord() bool {
+	return strings.HasPrefix(string(stType), recordPrefix) && !stType.IsArray()
+}
+
+// IsArray reports whether stType is an array type.
+func (stType STType) IsArray() bool {
+	return strings.HasSuffix(string(stType), "_array")
+}
+
+// ArrayOf returns the type of an array of stType.
+func (stType STType) ArrayOf() STType {
+	return stType + "_array"
+}
+
+// ElementType returns the element type of an array type, or STNone.
+func (stType STType) ElementType() STType {
+	if !stType.IsArray() {
+		return STNone
+	}
+	return STType(strings.TrimSuffix(string(stType), "_array"))
+}
+
+// ParamMode is how an argument is passed.  An in argument is copied
+// to the procedure; out and inout arguments are passed by reference,
+// so the procedure can change the caller's variable.  The modes are
+// not reserved words: they are identifiers after a parameter.
+type ParamMode string
+
+const (
+	// InMode ...
+	InMode ParamMode = "in"
+	// OutMode ...
+	OutMode ParamMode = "out"
+	// InoutMode ...
+	InoutMode ParamMode = "inout"
 )
 
+// IsReference reports whether arguments in this mode are passed by
+// reference.
+func (mode ParamMode) IsReference() bool {
+	return mode == OutMode || mode == InoutMode
+}
+
+// ProcedureArg is the type and mode of one procedure parameter.
+type ProcedureArg struct {
+	Type STType
+	Mode ParamMode
+}
+
+// STEntry describes a declared symbol.  Identifier is the lookup
+// key and Name the spelling used at the declaration.
 type STEntry struct {
 	Identifier          string
+	Name                string
 	EntryType           STType
 	IsArray             bool
 	ArraySize           int
-	ProcedureArgTypes   []STType
+	ProcedureArgTypes   []ProcedureArg
 	ProcedureReturnType STType
-	Pointer             int
+	// DefinedType is the type a type name stands for, EnumValues
+	// the identifiers of an enum type and Fields the fields of a
+	// record type, in order.
+	DefinedType STType
+	EnumValues  []string
+	Fields      []STEntry
+	// IsEnumValue marks an enum value, which has the enum type and
+	// its position in the declaration as EnumOrdinal.
+	IsEnumValue bool
+	EnumOrdinal int
+	// IsConstant marks a constant.  Value is nil until semantic
+	// analysis has evaluated it.
+	IsConstant bool
+	Value      *ConstantValue
+	// IgnorableResult marks a builtin procedure whose result only
+	// reports success, so a call statement may throw it away.
+	IgnorableResult bool
+}
+
+// ConstantValue is the value of a constant expression.  Int holds
+// integers, bools as 0 or 1 and enum values as their ordinal; floats
+// and strings have fields of their own.
+type ConstantValue struct {
+	Int    int64
+	Float  float64
+	String string
 }